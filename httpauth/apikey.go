@@ -0,0 +1,72 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// splitAPIKey splits an API key such as "sk_live_<random>" at its last
+// underscore into a prefix, "sk_live", and the key to look up.
+func splitAPIKey(apiKey string) (prefix, key string, ok bool) {
+	i := strings.LastIndexByte(apiKey, '_')
+	if i <= 0 || i == len(apiKey)-1 {
+		return "", "", false
+	}
+
+	return apiKey[:i], apiKey[i+1:], true
+}
+
+func apiKeyAuthentication(extract func(req *http.Request) string, lookup func(prefix, key string) (interface{}, bool, error), contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		prefix, key, ok := splitAPIKey(extract(req))
+		if !ok {
+			return req, false, nil
+		}
+
+		info, authentic, err := lookup(prefix, key)
+		if err != nil {
+			return req, false, err
+		}
+
+		if !authentic {
+			return req, false, nil
+		}
+
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, info)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+// APIKeyAuthentication authenticates an API key of the form
+// "<prefix>_<key>", such as "sk_live_<random>", in the named header. The key
+// is split at its last underscore, so the prefix may contain underscores but
+// the key may not, and lookup is called with both parts. Lookup returns the
+// info to place in the request context.
+func APIKeyAuthentication(lookup func(prefix, key string) (interface{}, bool, error), header string, contextKey interface{}) AuthenticationFunc {
+	return apiKeyAuthentication(func(req *http.Request) string {
+		return strings.TrimSpace(req.Header.Get(header))
+	}, lookup, contextKey)
+}
+
+func APIKeyAuthenticationHandler(handler http.Handler, lookup func(prefix, key string) (interface{}, bool, error), header string, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, APIKeyAuthentication(lookup, header, contextKey))
+}
+
+// APIKeyQueryAuthentication is APIKeyAuthentication with the API key taken
+// from the named query parameter, for clients that cannot set headers. Query
+// parameters end up in access logs, so prefer a header where possible.
+func APIKeyQueryAuthentication(lookup func(prefix, key string) (interface{}, bool, error), param string, contextKey interface{}) AuthenticationFunc {
+	return apiKeyAuthentication(func(req *http.Request) string {
+		return req.URL.Query().Get(param)
+	}, lookup, contextKey)
+}
+
+func APIKeyQueryAuthenticationHandler(handler http.Handler, lookup func(prefix, key string) (interface{}, bool, error), param string, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, APIKeyQueryAuthentication(lookup, param, contextKey))
+}
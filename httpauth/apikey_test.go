@@ -0,0 +1,72 @@
+package httpauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeyAuthenticationHandler(t *testing.T) {
+	lookupError := errors.New("lookup failed")
+	lookup := func(prefix, key string) (interface{}, bool, error) {
+		switch {
+		case prefix == "sk_live" && key == "abc123":
+			return "live", true, nil
+		case prefix == "sk_test" && key == "abc123":
+			return "test", true, nil
+		case key == "error":
+			return nil, false, lookupError
+		}
+
+		return nil, false, nil
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Value(testInfoKey{}).(string); !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	headerHandler := APIKeyAuthenticationHandler(next, lookup, "x-api-key", testInfoKey{})
+	queryHandler := APIKeyQueryAuthenticationHandler(next, lookup, "api_key", testInfoKey{})
+
+	for _, test := range []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"live", "sk_live_abc123", http.StatusOK},
+		{"test", "sk_test_abc123", http.StatusOK},
+		{"missing", "", http.StatusUnauthorized},
+		{"unknown key", "sk_live_def456", http.StatusUnauthorized},
+		{"unknown prefix", "pk_live_abc123", http.StatusUnauthorized},
+		{"no prefix", "abc123", http.StatusUnauthorized},
+		{"no key", "sk_live_", http.StatusUnauthorized},
+		{"lookup error", "sk_live_error", http.StatusInternalServerError},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.key != "" {
+			req.Header.Set("x-api-key", test.key)
+		}
+
+		w := httptest.NewRecorder()
+		headerHandler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: header request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+
+		req = httptest.NewRequest("GET", "/?api_key="+test.key, nil)
+
+		w = httptest.NewRecorder()
+		queryHandler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: query request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+}
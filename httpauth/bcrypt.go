@@ -0,0 +1,76 @@
+package httpauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptMaxPasswordLength is the most bytes of a password bcrypt uses.
+const bcryptMaxPasswordLength = 72
+
+// BcryptUserAuthenticator authenticates a single username and a password
+// stored as a bcrypt hash, so the password itself is never kept.
+type BcryptUserAuthenticator struct {
+	usernameDigest [sha256.Size]byte
+	hash           []byte
+}
+
+// NewBcryptUserAuthenticator hashes password with bcrypt at cost. A cost
+// below bcrypt.MinCost is replaced by bcrypt.DefaultCost, and one above
+// bcrypt.MaxCost is an error.
+//
+// bcrypt uses at most 72 bytes of a password, so longer passwords are
+// rejected here with bcrypt.ErrPasswordTooLong, and presented passwords
+// longer than 72 bytes are never authentic.
+func NewBcryptUserAuthenticator(username, password string, cost int) (*BcryptUserAuthenticator, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBcryptHashUserAuthenticator(username, hash)
+}
+
+// NewBcryptHashUserAuthenticator authenticates against an existing bcrypt
+// hash, such as one read from configuration.
+func NewBcryptHashUserAuthenticator(username string, hash []byte) (*BcryptUserAuthenticator, error) {
+	if _, err := bcrypt.Cost(hash); err != nil {
+		return nil, err
+	}
+
+	return &BcryptUserAuthenticator{
+		usernameDigest: sha256.Sum256([]byte(username)),
+		hash:           hash,
+	}, nil
+}
+
+func (b *BcryptUserAuthenticator) AuthenticateUser(username, password string) (info interface{}, authentic bool, err error) {
+	// The hash is checked even for a wrong username, so that response times
+	// do not reveal whether the username was correct.
+	usernameDigest := sha256.Sum256([]byte(username))
+	usernameMatch := subtle.ConstantTimeCompare(usernameDigest[:], b.usernameDigest[:]) == 1
+
+	// bcrypt would compare only the first bcryptMaxPasswordLength bytes, so
+	// a longer password could match with anything appended. Such passwords
+	// are rejected, after the same hash work, for the same reason.
+	passwordTooLong := len(password) > bcryptMaxPasswordLength
+	if passwordTooLong {
+		password = password[:bcryptMaxPasswordLength]
+	}
+
+	if err := bcrypt.CompareHashAndPassword(b.hash, []byte(password)); err != nil {
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	if !usernameMatch || passwordTooLong {
+		return nil, false, nil
+	}
+
+	return username, true, nil
+}
@@ -0,0 +1,89 @@
+package httpauth
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testBcryptHash is the bcrypt hash of "correct horse battery staple" at cost 4.
+const testBcryptHash = "$2a$04$YYIMze/hdAoUVN79W8GHsedSBxhZQfvfwmTBsdZTzkVLB8ha8L4Oi"
+
+func TestBcryptUserAuthenticator(t *testing.T) {
+	hashUserAuthenticator, err := NewBcryptHashUserAuthenticator("username", []byte(testBcryptHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passwordUserAuthenticator, err := NewBcryptUserAuthenticator("username", "correct horse battery staple", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, userAuthenticator := range []*BcryptUserAuthenticator{hashUserAuthenticator, passwordUserAuthenticator} {
+		for _, test := range []struct {
+			username, password string
+			authentic          bool
+		}{
+			{"username", "correct horse battery staple", true},
+			{"username", "correct horse battery", false},
+			{"username", "", false},
+			{"user", "correct horse battery staple", false},
+		} {
+			info, authentic, err := userAuthenticator.AuthenticateUser(test.username, test.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if authentic != test.authentic {
+				t.Errorf("%q:%q: authentic %t, expected %t", test.username, test.password, authentic, test.authentic)
+			}
+
+			if authentic && info != test.username {
+				t.Errorf("%q:%q: incorrect info %v", test.username, test.password, info)
+			}
+		}
+	}
+
+	if _, err := NewBcryptHashUserAuthenticator("username", []byte("not a hash")); err == nil {
+		t.Error("expected an error for an invalid hash")
+	}
+}
+
+func TestBcryptUserAuthenticatorLongPassword(t *testing.T) {
+	prefix := strings.Repeat("a", bcryptMaxPasswordLength)
+	hash, err := bcrypt.GenerateFromPassword([]byte(prefix), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userAuthenticator, err := NewBcryptHashUserAuthenticator("username", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewBcryptUserAuthenticator("username", prefix+"password", 4); err != bcrypt.ErrPasswordTooLong {
+		t.Errorf("long password: error %v, expected %v", err, bcrypt.ErrPasswordTooLong)
+	}
+
+	// bcrypt alone would accept both of the longer passwords, since they
+	// share the hashed 72-byte prefix.
+	for _, test := range []struct {
+		password  string
+		authentic bool
+	}{
+		{prefix, true},
+		{prefix + "password", false},
+		{prefix + "drowssap", false},
+	} {
+		_, authentic, err := userAuthenticator.AuthenticateUser("username", test.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authentic != test.authentic {
+			t.Errorf("%d-byte password: authentic %t, expected %t", len(test.password), authentic, test.authentic)
+		}
+	}
+}
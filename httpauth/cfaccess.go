@@ -0,0 +1,304 @@
+package httpauth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cloudflareAccessHeader = "cf-access-jwt-assertion"
+	jwksCacheDuration      = time.Hour
+	jwksRetryInterval      = 10 * time.Second
+
+	// jwtSep separates the header, payload and signature of a compact JWT.
+	jwtSep = "."
+)
+
+// CloudflareAccessClaims are the identity claims of a Cloudflare Access
+// application token.
+type CloudflareAccessClaims struct {
+	Subject   string   `json:"sub"`
+	Email     string   `json:"email"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+}
+
+// audience is a JWT aud claim, which may be a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte{'"'}) {
+		var single string
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+
+		*a = audience{single}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (a audience) contains(aud string) bool {
+	for _, candidate := range a {
+		if candidate == aud {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KeySource returns the RSA public keys trusted to sign tokens, by key ID.
+type KeySource func(ctx context.Context) (map[string]*rsa.PublicKey, error)
+
+type jwks struct {
+	Keys []struct {
+		KeyID   string `json:"kid"`
+		KeyType string `json:"kty"`
+		N       string `json:"n"`
+		E       string `json:"e"`
+	} `json:"keys"`
+}
+
+func fetchJWKS(ctx context.Context, url string) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("accept", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("keys endpoint returned status %d", res.StatusCode)
+	}
+
+	set := jwks{}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.KeyType != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[key.KeyID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+// JWKSKeys returns a KeySource that fetches a JSON Web Key Set from url and
+// caches it for an hour. For Cloudflare Access, url is
+// https://<team>.cloudflareaccess.com/cdn-cgi/access/certs.
+//
+// Only one fetch runs at a time; concurrent callers wait for its result
+// rather than fetching again. A failed fetch is not retried for ten seconds,
+// and callers in the meantime get its error, so that an unavailable endpoint
+// is not hit by every request.
+func JWKSKeys(url string) KeySource {
+	var (
+		mu       sync.Mutex
+		keys     map[string]*rsa.PublicKey
+		expires  time.Time
+		fetchErr error
+		retryAt  time.Time
+		fetching chan struct{}
+	)
+
+	return func(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+		for {
+			mu.Lock()
+			now := time.Now()
+			if keys != nil && now.Before(expires) {
+				mu.Unlock()
+				return keys, nil
+			}
+
+			if fetchErr != nil && now.Before(retryAt) {
+				mu.Unlock()
+				return nil, fetchErr
+			}
+
+			if fetching == nil {
+				break
+			}
+
+			done := fetching
+			mu.Unlock()
+
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		done := make(chan struct{})
+		fetching = done
+		mu.Unlock()
+
+		// The fetch runs without the lock, so that callers with cached keys
+		// are not held up by it.
+		fetched, err := fetchJWKS(ctx, url)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		fetching = nil
+		close(done)
+
+		if err != nil {
+			// A fetch given up by its caller says nothing about the
+			// endpoint, so waiting callers try again themselves.
+			if ctx.Err() == nil {
+				fetchErr, retryAt = err, time.Now().Add(jwksRetryInterval)
+			}
+
+			return nil, err
+		}
+
+		keys, expires, fetchErr = fetched, time.Now().Add(jwksCacheDuration), nil
+		return keys, nil
+	}
+}
+
+// verifyRS256 returns the claims of a compact RS256 JWT signed by one of
+// keys, or false if it is malformed or not signed by any of them.
+func verifyRS256(token string, keys map[string]*rsa.PublicKey, claims interface{}) bool {
+	parts := strings.Split(token, jwtSep)
+	if len(parts) != 3 {
+		return false
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+
+	header := struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}{}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return false
+	}
+
+	// The algorithm is fixed rather than taken from the token, so that
+	// unsigned and HMAC tokens are never accepted.
+	if header.Algorithm != "RS256" {
+		return false
+	}
+
+	key, ok := keys[header.KeyID]
+	if !ok {
+		return false
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(token[:len(parts[0])+1+len(parts[1])]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(payload, claims) == nil
+}
+
+// CloudflareAccessAuthentication authenticates requests by the token that
+// Cloudflare Access adds in the Cf-Access-Jwt-Assertion header, verifying
+// its signature against keys and that it was issued for the application
+// with the audience tag aud. The *CloudflareAccessClaims are placed in the
+// request context.
+//
+// The origin must only be reachable through Cloudflare, for example with
+// Cloudflare Tunnel, or the header can be sent by anyone holding a token for
+// the application.
+func CloudflareAccessAuthentication(keys KeySource, aud string, contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		token := req.Header.Get(cloudflareAccessHeader)
+		if token == "" {
+			return req, false, nil
+		}
+
+		trustedKeys, err := keys(req.Context())
+		if err != nil {
+			return req, false, err
+		}
+
+		claims := &CloudflareAccessClaims{}
+		if !verifyRS256(token, trustedKeys, claims) {
+			return req, false, nil
+		}
+
+		now := time.Now()
+		if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(ClockSkew)) {
+			return req, false, nil
+		}
+
+		if now.Add(ClockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+			return req, false, nil
+		}
+
+		if !claims.Audience.contains(aud) {
+			return req, false, nil
+		}
+
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, claims)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func CloudflareAccessAuthenticationHandler(handler http.Handler, keys KeySource, aud string, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, CloudflareAccessAuthentication(keys, aud, contextKey))
+}
@@ -0,0 +1,264 @@
+package httpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, header, claims interface{}) string {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestCloudflareAccessAuthentication(t *testing.T) {
+	const aud = "application-aud"
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+		return map[string]*rsa.PublicKey{"kid": &key.PublicKey}, nil
+	}
+
+	handler := CloudflareAccessAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		claims, ok := req.Context().Value(testInfoKey{}).(*CloudflareAccessClaims)
+		if !ok || claims.Email != "user@example.com" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), keys, aud, testInfoKey{})
+
+	header := map[string]string{"alg": "RS256", "kid": "kid"}
+	claims := func(aud interface{}, exp time.Time) map[string]interface{} {
+		return map[string]interface{}{
+			"sub":   "subject",
+			"email": "user@example.com",
+			"aud":   aud,
+			"exp":   exp.Unix(),
+			"iat":   time.Now().Unix(),
+		}
+	}
+
+	valid := signRS256(t, key, header, claims([]string{aud}, time.Now().Add(time.Hour)))
+	tampered := signRS256(t, key, header, claims([]string{aud}, time.Now().Add(time.Hour)))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	unsignedHeader, _ := json.Marshal(map[string]string{"alg": "none", "kid": "kid"})
+	unsignedClaims, _ := json.Marshal(claims(aud, time.Now().Add(time.Hour)))
+	unsigned := base64.RawURLEncoding.EncodeToString(unsignedHeader) + "." + base64.RawURLEncoding.EncodeToString(unsignedClaims) + "."
+
+	for _, test := range []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"string audience", signRS256(t, key, header, claims(aud, time.Now().Add(time.Hour))), http.StatusOK},
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong audience", signRS256(t, key, header, claims([]string{"other-aud"}, time.Now().Add(time.Hour))), http.StatusUnauthorized},
+		{"expired", signRS256(t, key, header, claims(aud, time.Now().Add(-time.Hour))), http.StatusUnauthorized},
+		{"unsigned", unsigned, http.StatusUnauthorized},
+		{"tampered", tampered, http.StatusUnauthorized},
+		{"untrusted key", signRS256(t, otherKey, header, claims(aud, time.Now().Add(time.Hour))), http.StatusUnauthorized},
+		{"unknown key ID", signRS256(t, key, map[string]string{"alg": "RS256", "kid": "other"}, claims(aud, time.Now().Add(time.Hour))), http.StatusUnauthorized},
+		{"garbage", "garbage", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.token != "" {
+			req.Header.Set("Cf-Access-Jwt-Assertion", test.token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+
+	failingHandler := CloudflareAccessAuthenticationHandler(http.NotFoundHandler(), func(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+		return nil, errors.New("keys unavailable")
+	}, aud, nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	failingHandler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("request without assertion returned status %d, expected %d", w.Code, http.StatusUnauthorized)
+	}
+
+	req.Header.Set("Cf-Access-Jwt-Assertion", valid)
+	w = httptest.NewRecorder()
+	failingHandler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("request with unavailable keys returned status %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestJWKSKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	keysServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "kid",
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer keysServer.Close()
+
+	keys := JWKSKeys(keysServer.URL)
+	for i := 0; i < 2; i++ {
+		trustedKeys, err := keys(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if publicKey, ok := trustedKeys["kid"]; !ok || !publicKey.Equal(&key.PublicKey) {
+			t.Errorf("incorrect keys, %v", trustedKeys)
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("keys fetched %d times, expected once", requests)
+	}
+}
+
+func TestJWKSKeysConcurrent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int32
+	release := make(chan struct{})
+	keysServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "kid",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer keysServer.Close()
+
+	keys := JWKSKeys(keysServer.URL)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys(context.Background())
+			errs <- err
+		}()
+	}
+
+	// Give the callers time to queue behind the first fetch.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Errorf("keys fetched %d times, expected once", requests)
+	}
+}
+
+func TestJWKSKeysRetryInterval(t *testing.T) {
+	requests := 0
+	keysServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer keysServer.Close()
+
+	keys := JWKSKeys(keysServer.URL)
+	for i := 0; i < 2; i++ {
+		if _, err := keys(context.Background()); err == nil {
+			t.Error("expected an error for unavailable keys")
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("keys fetched %d times after a failure, expected once", requests)
+	}
+
+	// A fetch abandoned by its caller is not held against the endpoint.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	otherKeys := JWKSKeys(keysServer.URL)
+	if _, err := otherKeys(ctx); err == nil {
+		t.Error("expected an error for a canceled fetch")
+	}
+
+	if _, err := otherKeys(context.Background()); err == nil {
+		t.Error("expected an error for unavailable keys")
+	}
+
+	if requests != 2 {
+		t.Errorf("keys fetched %d times, expected twice", requests)
+	}
+}
@@ -0,0 +1,105 @@
+package httpauth
+
+import (
+	"net/http"
+)
+
+// ChainAuthentication tries each of authenticationFuncs in order, succeeding
+// with the first that authenticates the request and failing with the first
+// error. If none authenticate, the challenge headers set by each are returned
+// together as separate WWW-Authenticate values, so the client can choose a
+// scheme.
+func ChainAuthentication(authenticationFuncs ...AuthenticationFunc) AuthenticationFunc {
+	return chainAuthentication(true, authenticationFuncs)
+}
+
+// AnyAuthentication behaves like ChainAuthentication, but an error from one
+// of authenticationFuncs does not stop the others from being tried, so that,
+// for example, Basic credentials still work while a session store is down.
+// If none authenticate, the last error is returned.
+func AnyAuthentication(authenticationFuncs ...AuthenticationFunc) AuthenticationFunc {
+	return chainAuthentication(false, authenticationFuncs)
+}
+
+func chainAuthentication(stopOnError bool, authenticationFuncs []AuthenticationFunc) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		header := w.Header()
+		previous := header.Values("www-authenticate")
+
+		var (
+			challenges []string
+			lastErr    error
+		)
+		for _, authenticationFunc := range authenticationFuncs {
+			// Authenticators replace the header, so collect each one's
+			// challenges separately.
+			header.Del("www-authenticate")
+			authenticationReq, authentic, err := authenticationFunc(w, req)
+			challenges = append(challenges, header.Values("www-authenticate")...)
+
+			if err != nil {
+				if stopOnError {
+					setChallenges(header, previous, challenges)
+					return authenticationReq, false, err
+				}
+
+				lastErr = err
+				continue
+			}
+
+			if authentic {
+				setChallenges(header, previous, nil)
+				return authenticationReq, true, nil
+			}
+		}
+
+		setChallenges(header, previous, challenges)
+		return req, false, lastErr
+	}
+}
+
+// AllAuthentication authenticates a request only if every one of
+// authenticationFuncs does, such as an API key identifying an application
+// and a bearer token identifying a user. The funcs run in order, each given
+// the request returned by the one before, so each func's context values are
+// visible to the next and to the handler; use a distinct context key for
+// each, since a later value under the same key, including the session ID
+// reported by SessionIDFromContext, shadows an earlier one. The first func to
+// fail stops the rest, and its challenge headers are left for the client.
+func AllAuthentication(authenticationFuncs ...AuthenticationFunc) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		authenticationReq := req
+		for _, authenticationFunc := range authenticationFuncs {
+			var (
+				authentic bool
+				err       error
+			)
+
+			authenticationReq, authentic, err = authenticationFunc(w, authenticationReq)
+			if err != nil || !authentic {
+				return req, false, err
+			}
+		}
+
+		return authenticationReq, true, nil
+	}
+}
+
+func setChallenges(header http.Header, previous, challenges []string) {
+	header.Del("www-authenticate")
+	for _, challenge := range append(previous, challenges...) {
+		header.Add("www-authenticate", challenge)
+	}
+}
+
+func ChainAuthenticationHandler(handler http.Handler, authenticationFuncs ...AuthenticationFunc) http.Handler {
+	return AuthenticationHandler(handler, ChainAuthentication(authenticationFuncs...))
+}
+
+func AnyAuthenticationHandler(handler http.Handler, authenticationFuncs ...AuthenticationFunc) http.Handler {
+	return AuthenticationHandler(handler, AnyAuthentication(authenticationFuncs...))
+}
+
+func AllAuthenticationHandler(handler http.Handler, authenticationFuncs ...AuthenticationFunc) http.Handler {
+	return AuthenticationHandler(handler, AllAuthentication(authenticationFuncs...))
+}
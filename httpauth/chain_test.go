@@ -0,0 +1,157 @@
+package httpauth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestChainAuthenticationHandler(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := ChainAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}),
+		BasicAuthentication("test", NewSingleUserAuthenticator("username", "password"), nil),
+		BearerRealmAuthentication("test", NewSingleTokenAuthenticator(token), testInfoKey{}),
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("server allowed unauthenticated request with status %d", w.Code)
+	}
+
+	challenges := w.Header().Values("www-authenticate")
+	if len(challenges) != 2 || challenges[0] != `Basic realm="test"` || challenges[1] != `Bearer realm="test"` {
+		t.Errorf("incorrect challenge headers %q", challenges)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("authorization", "Bearer "+token.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("authenticated request failed with status %d", w.Code)
+	}
+
+	if challenges := w.Header().Values("www-authenticate"); len(challenges) != 0 {
+		t.Errorf("unexpected challenge headers %q on authenticated request", challenges)
+	}
+}
+
+func TestAnyAuthentication(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticationFunc := AnyAuthentication(
+		BearerAuthentication(failingTokenAuthenticator{}, testInfoKey{}),
+		BasicAuthentication("test", NewSingleUserAuthenticator("username", "password"), testInfoKey{}),
+		BearerRealmAuthentication("test", NewSingleTokenAuthenticator(token), testInfoKey{}),
+	)
+
+	for _, test := range []struct {
+		name          string
+		authorization string
+		authentic     bool
+		err           bool
+		info          interface{}
+	}{
+		{"basic", "Basic " + base64.StdEncoding.EncodeToString([]byte("username:password")), true, false, "username"},
+		{"bearer", "Bearer " + token.String(), true, false, token},
+		{"wrong basic", "Basic " + base64.StdEncoding.EncodeToString([]byte("username:wrong")), false, false, nil},
+		{"unknown bearer", "Bearer garbage", false, false, nil},
+		{"bearer error", "Bearer " + id.ID{1}.String(), false, true, nil},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("authorization", test.authorization)
+
+		authenticationReq, authentic, err := authenticationFunc(httptest.NewRecorder(), req)
+		if authentic != test.authentic {
+			t.Errorf("%s: authentic %t, expected %t", test.name, authentic, test.authentic)
+		}
+
+		if (err != nil) != test.err {
+			t.Errorf("%s: error %v", test.name, err)
+		}
+
+		if info := authenticationReq.Context().Value(testInfoKey{}); info != test.info {
+			t.Errorf("%s: info %v, expected %v", test.name, info, test.info)
+		}
+	}
+}
+
+type testAppKey struct{}
+
+func TestAllAuthentication(t *testing.T) {
+	apiKey, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := AllAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		app, ok := req.Context().Value(testAppKey{}).(id.ID)
+		if !ok || app != apiKey {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		user, ok := req.Context().Value(testInfoKey{}).(id.ID)
+		if !ok || user != token {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}),
+		TokenHeaderAuthentication(NewSingleTokenAuthenticator(apiKey), testAppKey{}, "x-api-key"),
+		BearerRealmAuthentication("test", NewSingleTokenAuthenticator(token), testInfoKey{}),
+	)
+
+	for _, test := range []struct {
+		name           string
+		apiKey, bearer string
+		status         int
+		challenge      string
+	}{
+		{"both", apiKey.String(), token.String(), http.StatusOK, ""},
+		{"api key only", apiKey.String(), "", http.StatusUnauthorized, `Bearer realm="test"`},
+		{"bearer only", "", token.String(), http.StatusUnauthorized, ""},
+		{"swapped", token.String(), apiKey.String(), http.StatusUnauthorized, ""},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.apiKey != "" {
+			req.Header.Set("x-api-key", test.apiKey)
+		}
+		if test.bearer != "" {
+			req.Header.Set("authorization", "Bearer "+test.bearer)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+
+		if challenge := w.Header().Get("www-authenticate"); challenge != test.challenge {
+			t.Errorf("%s: challenge %q, expected %q", test.name, challenge, test.challenge)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package httpauth
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+)
+
+// ClientCertAuthentication authenticates requests by their TLS client
+// certificate, which verify checks, for example against an allowlist of
+// subjects or SANs, returning the info to place in the request context.
+//
+// Only certificates the server verified are considered, so the server's
+// tls.Config must set ClientCAs and a ClientAuth of VerifyClientCertIfGiven or
+// RequireAndVerifyClientCert. Requests without a verified certificate are not
+// authenticated.
+func ClientCertAuthentication(verify func(*x509.Certificate) (interface{}, bool, error), contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 || len(req.TLS.VerifiedChains) == 0 {
+			return req, false, nil
+		}
+
+		info, authentic, err := verify(req.TLS.PeerCertificates[0])
+		if err != nil {
+			return req, false, err
+		}
+
+		if !authentic {
+			return req, false, nil
+		}
+
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, info)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func ClientCertAuthenticationHandler(handler http.Handler, verify func(*x509.Certificate) (interface{}, bool, error), contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, ClientCertAuthentication(verify, contextKey))
+}
@@ -0,0 +1,134 @@
+package httpauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a certificate for commonName signed by parent, or
+// self-signed if parent is nil.
+func testCertificate(t *testing.T, commonName string, isCA bool, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	parentCertificate, parentKey := template, interface{}(key)
+	if parent != nil {
+		parentCertificate, parentKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCertificate, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestClientCertAuthenticationHandler(t *testing.T) {
+	ca := testCertificate(t, "ca", true, nil)
+	allowed := testCertificate(t, "allowed", false, &ca)
+	denied := testCertificate(t, "denied", false, &ca)
+	selfSigned := testCertificate(t, "allowed", false, nil)
+
+	server := httptest.NewUnstartedServer(ClientCertAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if commonName, ok := req.Context().Value(testInfoKey{}).(string); !ok || commonName != "allowed" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), func(certificate *x509.Certificate) (interface{}, bool, error) {
+		if certificate.Subject.CommonName != "allowed" {
+			return nil, false, nil
+		}
+
+		return certificate.Subject.CommonName, true, nil
+	}, testInfoKey{}))
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Leaf)
+	server.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	request := func(certificates ...tls.Certificate) (int, error) {
+		// Each request gets its own transport, so that connections, and the
+		// certificates presented on them, are not reused.
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = certificates
+		client := &http.Client{Transport: transport}
+
+		response, err := client.Get(server.URL)
+		if err != nil {
+			return 0, err
+		}
+		defer response.Body.Close()
+
+		return response.StatusCode, nil
+	}
+
+	for _, test := range []struct {
+		name         string
+		certificates []tls.Certificate
+		status       int
+	}{
+		{"allowed", []tls.Certificate{allowed}, http.StatusOK},
+		{"denied", []tls.Certificate{denied}, http.StatusUnauthorized},
+		{"no certificate", nil, http.StatusUnauthorized},
+	} {
+		status, err := request(test.certificates...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if status != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, status, test.status)
+		}
+	}
+
+	// A certificate from another CA is either not sent, as it matches none
+	// of the server's CAs, or fails the handshake.
+	if status, err := request(selfSigned); err == nil && status != http.StatusUnauthorized {
+		t.Errorf("self-signed: request returned status %d, expected %d", status, http.StatusUnauthorized)
+	}
+
+	// Without verification by the server, even a matching certificate is not
+	// trusted.
+	unverified := httptest.NewRequest("GET", "/", nil)
+	unverified.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{allowed.Leaf}}
+	if _, authentic, err := ClientCertAuthentication(func(*x509.Certificate) (interface{}, bool, error) {
+		return nil, true, nil
+	}, nil)(httptest.NewRecorder(), unverified); err != nil || authentic {
+		t.Errorf("unverified certificate: authentic %t, error %v", authentic, err)
+	}
+}
@@ -0,0 +1,343 @@
+package httpauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DigestNonceLifetime is how long a nonce issued by DigestAuthentication is
+// accepted. Clients presenting an older nonce are challenged with a new one
+// marked stale, so they can retry without asking the user again.
+var DigestNonceLifetime = 5 * time.Minute
+
+// DigestUserAuthenticator looks up users for DigestAuthentication, which
+// needs a hash of the password rather than the password itself.
+type DigestUserAuthenticator interface {
+	// DigestHA1 returns the hex-encoded hash of "username:realm:password"
+	// with algorithm, "MD5" or "SHA-256", and the info for the user.
+	DigestHA1(username, realm, algorithm string) (ha1 string, info interface{}, ok bool, err error)
+}
+
+// SingleDigestUserAuthenticator is a DigestUserAuthenticator for a single
+// username and password. The password is kept in memory.
+type SingleDigestUserAuthenticator struct {
+	usernameDigest [sha256.Size]byte
+	username       string
+	password       string
+}
+
+func NewSingleDigestUserAuthenticator(username, password string) *SingleDigestUserAuthenticator {
+	return &SingleDigestUserAuthenticator{
+		usernameDigest: sha256.Sum256([]byte(username)),
+		username:       username,
+		password:       password,
+	}
+}
+
+func (s *SingleDigestUserAuthenticator) DigestHA1(username, realm, algorithm string) (ha1 string, info interface{}, ok bool, err error) {
+	usernameDigest := sha256.Sum256([]byte(username))
+	if subtle.ConstantTimeCompare(usernameDigest[:], s.usernameDigest[:]) != 1 {
+		return "", nil, false, nil
+	}
+
+	return digestHash(algorithm, s.username+":"+realm+":"+s.password), s.username, true, nil
+}
+
+// digestHash returns the hex-encoded hash of s with algorithm, "MD5" or
+// "SHA-256".
+func digestHash(algorithm, s string) string {
+	if algorithm == "MD5" {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// digestResponse returns the expected response to a qop=auth challenge, as
+// RFC 7616 section 3.4.1 defines it.
+func digestResponse(algorithm, ha1, nonce, nc, cnonce, method, uri string) string {
+	ha2 := digestHash(algorithm, method+":"+uri)
+	return digestHash(algorithm, ha1+":"+nonce+":"+nc+":"+cnonce+":auth:"+ha2)
+}
+
+// parseAuthParams parses a comma-separated list of auth-params, as in a
+// Digest Authorization header. Parameter names are lowercased and quoted
+// values unescaped.
+func parseAuthParams(s string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params, len(params) > 0
+		}
+
+		i := strings.IndexByte(s, '=')
+		if i <= 0 {
+			return nil, false
+		}
+
+		name := strings.ToLower(strings.TrimRight(s[:i], " \t"))
+		if name == "" || strings.ContainsAny(name, " \t,\"") {
+			return nil, false
+		}
+
+		s = strings.TrimLeft(s[i+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var unquoted strings.Builder
+			j := 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				unquoted.WriteByte(s[j])
+			}
+
+			if j == len(s) {
+				return nil, false
+			}
+
+			value, s = unquoted.String(), s[j+1:]
+		} else {
+			j := strings.IndexAny(s, ", \t")
+			if j < 0 {
+				j = len(s)
+			}
+
+			value, s = s[:j], s[j:]
+		}
+
+		if _, ok := params[name]; ok {
+			return nil, false
+		}
+
+		params[name] = value
+
+		s = strings.TrimLeft(s, " \t")
+		if s != "" && s[0] != ',' {
+			return nil, false
+		}
+	}
+}
+
+// digestParams returns the auth-params of a Digest Authorization header
+// value.
+func digestParams(authorization string) (map[string]string, bool) {
+	const scheme = "Digest"
+	authorization = strings.TrimSpace(authorization)
+	if len(authorization) <= len(scheme) || !strings.EqualFold(authorization[:len(scheme)], scheme) {
+		return nil, false
+	}
+
+	if c := authorization[len(scheme)]; c != ' ' && c != '\t' {
+		return nil, false
+	}
+
+	return parseAuthParams(authorization[len(scheme)+1:])
+}
+
+type digestNonceCount struct {
+	nc      uint64
+	expires time.Time
+}
+
+// digestNonces issues nonces that carry their issue time and an HMAC, so
+// that they can be checked without being stored, and tracks the highest nonce
+// count used with each so that requests cannot be replayed.
+type digestNonces struct {
+	key       []byte
+	mu        sync.Mutex
+	counts    map[string]digestNonceCount
+	nextSweep time.Time
+}
+
+func newDigestNonces() (*digestNonces, error) {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return &digestNonces{
+		key:    key,
+		counts: make(map[string]digestNonceCount),
+	}, nil
+}
+
+func (d *digestNonces) mac(data []byte) []byte {
+	mac := hmac.New(sha256.New, d.key)
+	mac.Write(data)
+	return mac.Sum(nil)[:16]
+}
+
+func (d *digestNonces) issue(now time.Time) (string, error) {
+	nonce := make([]byte, 16, 32)
+	binary.BigEndian.PutUint64(nonce, uint64(now.UnixNano()))
+	if _, err := rand.Read(nonce[8:]); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(append(nonce, d.mac(nonce)...)), nil
+}
+
+// issued returns the time nonce was issued, if it was issued by d.
+func (d *digestNonces) issued(nonce string) (time.Time, bool) {
+	decoded, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(decoded) != 32 {
+		return time.Time{}, false
+	}
+
+	if !hmac.Equal(decoded[16:], d.mac(decoded[:16])) {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(decoded))), true
+}
+
+// use records nc as used with nonce, reporting false if it is not higher
+// than a count already used.
+func (d *digestNonces) use(nonce string, nc uint64, expires, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.After(d.nextSweep) {
+		for nonce, count := range d.counts {
+			if now.After(count.expires) {
+				delete(d.counts, nonce)
+			}
+		}
+
+		d.nextSweep = now.Add(DigestNonceLifetime)
+	}
+
+	if count, ok := d.counts[nonce]; ok && nc <= count.nc {
+		return false
+	}
+
+	d.counts[nonce] = digestNonceCount{nc: nc, expires: expires}
+	return true
+}
+
+// DigestAuthentication authenticates requests with HTTP Digest
+// authentication, as RFC 7616 describes, for clients that support nothing
+// else. Only qop=auth is supported, with the SHA-256 and MD5 algorithms; the
+// client is offered both, SHA-256 first.
+//
+// Nonces are valid for DigestNonceLifetime and each nonce count may be used
+// once, so a captured request cannot be replayed. Used nonce counts are kept
+// in memory, so with several servers a request could be replayed against
+// each of them once. Control characters in realm are dropped from the
+// challenge, and clients must echo the realm without them; see ValidRealm.
+func DigestAuthentication(realm string, userAuthenticator DigestUserAuthenticator, contextKey interface{}) AuthenticationFunc {
+	realm = normalizeRealm(realm)
+	quotedRealm := quotedString(realm)
+
+	// Without a nonce key no request can be authenticated, so every request
+	// fails with the error.
+	nonces, err := newDigestNonces()
+	if err != nil {
+		return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+			return req, false, err
+		}
+	}
+
+	challenge := func(w http.ResponseWriter, stale bool) error {
+		nonce, err := nonces.issue(time.Now())
+		if err != nil {
+			return err
+		}
+
+		for _, algorithm := range []string{"SHA-256", "MD5"} {
+			authenticateHeader := "Digest realm=" + quotedRealm + `, qop="auth", algorithm=` + algorithm + `, nonce="` + nonce + `"`
+			if stale {
+				authenticateHeader += ", stale=true"
+			}
+
+			w.Header().Add("www-authenticate", authenticateHeader)
+		}
+
+		return nil
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		params, ok := digestParams(req.Header.Get("authorization"))
+		if !ok {
+			return req, false, challenge(w, false)
+		}
+
+		algorithm := strings.ToUpper(params["algorithm"])
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+
+		uri := req.URL.RequestURI()
+		if (algorithm != "MD5" && algorithm != "SHA-256") || params["qop"] != "auth" || params["realm"] != realm || params["uri"] != uri || params["cnonce"] == "" {
+			return req, false, challenge(w, false)
+		}
+
+		nc, err := strconv.ParseUint(params["nc"], 16, 64)
+		if err != nil || nc == 0 {
+			return req, false, challenge(w, false)
+		}
+
+		nonce := params["nonce"]
+		issued, ok := nonces.issued(nonce)
+		if !ok {
+			return req, false, challenge(w, false)
+		}
+
+		expires := issued.Add(DigestNonceLifetime)
+		now := time.Now()
+		if now.After(expires) {
+			return req, false, challenge(w, true)
+		}
+
+		// An error is a failure to authenticate, not a rejection of the
+		// credentials, so the client is not challenged for new ones.
+		ha1, info, ok, err := userAuthenticator.DigestHA1(params["username"], realm, algorithm)
+		if err != nil {
+			return req, false, err
+		}
+
+		if !ok {
+			return req, false, challenge(w, false)
+		}
+
+		response := digestResponse(algorithm, ha1, nonce, params["nc"], params["cnonce"], req.Method, uri)
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(params["response"])), []byte(response)) != 1 {
+			return req, false, challenge(w, false)
+		}
+
+		// The nonce count is recorded only once the response is verified, so
+		// that forged requests cannot use up a client's counts.
+		if !nonces.use(nonce, nc, expires, now) {
+			return req, false, challenge(w, false)
+		}
+
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, info)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func DigestAuthenticationHandler(handler http.Handler, realm string, userAuthenticator DigestUserAuthenticator, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, DigestAuthentication(realm, userAuthenticator, contextKey))
+}
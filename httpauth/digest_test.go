@@ -0,0 +1,148 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestResponse(t *testing.T) {
+	// The examples of RFC 7616 section 3.9.1.
+	for _, test := range []struct {
+		algorithm, response string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	} {
+		ha1 := digestHash(test.algorithm, "Mufasa:http-auth@example.org:Circle of Life")
+		response := digestResponse(test.algorithm, ha1, "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", "00000001", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", "GET", "/dir/index.html")
+		if response != test.response {
+			t.Errorf("%s: response %s, expected %s", test.algorithm, response, test.response)
+		}
+	}
+}
+
+func TestParseAuthParams(t *testing.T) {
+	params, ok := parseAuthParams(`username="Mufasa", Realm="a \"quoted\", realm",qop=auth,  nc=00000001`)
+	if !ok {
+		t.Fatal("expected params to parse")
+	}
+
+	for name, value := range map[string]string{
+		"username": "Mufasa",
+		"realm":    `a "quoted", realm`,
+		"qop":      "auth",
+		"nc":       "00000001",
+	} {
+		if params[name] != value {
+			t.Errorf("%s: value %q, expected %q", name, params[name], value)
+		}
+	}
+
+	for _, s := range []string{``, `username`, `username="Mufasa`, `username=a b`, `qop=auth, qop=auth`} {
+		if _, ok := parseAuthParams(s); ok {
+			t.Errorf("%q: expected params not to parse", s)
+		}
+	}
+}
+
+func TestDigestAuthenticationHandler(t *testing.T) {
+	handler := DigestAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if username, ok := req.Context().Value(testInfoKey{}).(string); !ok || username != "username" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), "realm", NewSingleDigestUserAuthenticator("username", "password"), testInfoKey{})
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/resource?q=1", nil)
+		if authorization != "" {
+			req.Header.Set("authorization", authorization)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// challenge returns the nonce offered for algorithm.
+	challenge := func(w *httptest.ResponseRecorder, algorithm string) (string, bool) {
+		for _, authenticateHeader := range w.Header().Values("www-authenticate") {
+			params, ok := digestParams(authenticateHeader)
+			if !ok {
+				t.Fatalf("invalid challenge %q", authenticateHeader)
+			}
+
+			if params["algorithm"] == algorithm {
+				if params["realm"] != "realm" || params["qop"] != "auth" {
+					t.Errorf("incorrect challenge %q", authenticateHeader)
+				}
+
+				return params["nonce"], params["stale"] == "true"
+			}
+		}
+
+		t.Fatalf("no %s challenge", algorithm)
+		return "", false
+	}
+
+	authorization := func(algorithm, username, password, nonce, nc string) string {
+		ha1 := digestHash(algorithm, username+":realm:"+password)
+		response := digestResponse(algorithm, ha1, nonce, nc, "cnonce", "GET", "/resource?q=1")
+		return `Digest username="` + username + `", realm="realm", uri="/resource?q=1", algorithm=` + algorithm + `, qop=auth, nc=` + nc + `, cnonce="cnonce", nonce="` + nonce + `", response="` + response + `"`
+	}
+
+	for _, algorithm := range []string{"SHA-256", "MD5"} {
+		w := serve("")
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: unauthenticated request returned status %d", algorithm, w.Code)
+		}
+
+		nonce, _ := challenge(w, algorithm)
+
+		for _, test := range []struct {
+			name          string
+			authorization string
+			status        int
+		}{
+			{"valid", authorization(algorithm, "username", "password", nonce, "00000001"), http.StatusOK},
+			{"replayed", authorization(algorithm, "username", "password", nonce, "00000001"), http.StatusUnauthorized},
+			{"next count", authorization(algorithm, "username", "password", nonce, "00000002"), http.StatusOK},
+			{"wrong password", authorization(algorithm, "username", "wrong", nonce, "00000003"), http.StatusUnauthorized},
+			{"wrong username", authorization(algorithm, "wrong", "password", nonce, "00000003"), http.StatusUnauthorized},
+			{"forged nonce", authorization(algorithm, "username", "password", "bm9uY2U", "00000003"), http.StatusUnauthorized},
+			{"wrong uri", strings.Replace(authorization(algorithm, "username", "password", nonce, "00000003"), "/resource?q=1", "/resource?q=2", 1), http.StatusUnauthorized},
+			{"basic", "Basic dXNlcm5hbWU6cGFzc3dvcmQ=", http.StatusUnauthorized},
+		} {
+			w := serve(test.authorization)
+			if w.Code != test.status {
+				t.Errorf("%s: %s: request returned status %d, expected %d", algorithm, test.name, w.Code, test.status)
+			}
+
+			if w.Code == http.StatusUnauthorized {
+				if _, stale := challenge(w, algorithm); stale {
+					t.Errorf("%s: %s: unexpected stale challenge", algorithm, test.name)
+				}
+			}
+		}
+	}
+
+	defer func(lifetime time.Duration) {
+		DigestNonceLifetime = lifetime
+	}(DigestNonceLifetime)
+	DigestNonceLifetime = -time.Second
+
+	nonce, _ := challenge(serve(""), "SHA-256")
+	w := serve(authorization("SHA-256", "username", "password", nonce, "00000001"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("stale: request returned status %d, expected %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if _, stale := challenge(w, "SHA-256"); !stale {
+		t.Error("expected a stale challenge for an expired nonce")
+	}
+}
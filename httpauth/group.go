@@ -0,0 +1,56 @@
+package httpauth
+
+import (
+	"net/http"
+
+	"github.com/O-C-R/auth/session"
+)
+
+// SessionGrouper looks up the group of a session, as
+// session.SessionStore.SessionGroup does.
+type SessionGrouper interface {
+	SessionGroup(sessionID interface{}) (string, error)
+}
+
+// RequireGroup serves requests with handler only if the request's session
+// belongs to the group groupMatcher expects for it, such as a tenant named
+// in the route. groupMatcher is passed the authentication info stored under
+// contextKey; if it returns false, no group may use the route. Sessions
+// without a group never match.
+//
+// The session is the one stored in the context by the token and session
+// cookie authentication funcs, so RequireGroup should wrap one of their
+// handlers. Requests without a session, or whose session no longer exists,
+// are unauthorized, and those from another group are forbidden.
+func RequireGroup(handler http.Handler, sessionGrouper SessionGrouper, contextKey interface{}, groupMatcher func(info interface{}, req *http.Request) (string, bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sessionID, ok := SessionIDFromContext(req.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		expected, ok := groupMatcher(req.Context().Value(contextKey), req)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		group, err := sessionGrouper.SessionGroup(sessionID)
+		if err == session.NoSessionFoundError {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if group == "" || group != expected {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
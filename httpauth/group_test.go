@@ -0,0 +1,82 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/O-C-R/auth/session"
+)
+
+type testSessionGrouper map[id.ID]string
+
+func (g testSessionGrouper) SessionGroup(sessionID interface{}) (string, error) {
+	group, ok := g[sessionID.(id.ID)]
+	if !ok {
+		return "", session.NoSessionFoundError
+	}
+
+	return group, nil
+}
+
+func TestRequireGroup(t *testing.T) {
+	newID := func() id.ID {
+		sessionID, err := id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return sessionID
+	}
+
+	tenantSessionID, otherSessionID, ungroupedSessionID, unknownSessionID := newID(), newID(), newID(), newID()
+	sessionGrouper := testSessionGrouper{
+		tenantSessionID:    "tenant",
+		otherSessionID:     "other",
+		ungroupedSessionID: "",
+	}
+
+	// The route's tenant is taken from the query, and only sessions with
+	// info may use it.
+	handler := RequireGroup(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), sessionGrouper, testInfoKey{}, func(info interface{}, req *http.Request) (string, bool) {
+		if info == nil {
+			return "", false
+		}
+
+		return req.URL.Query().Get("tenant"), true
+	})
+
+	for _, test := range []struct {
+		name      string
+		sessionID *id.ID
+		info      interface{}
+		status    int
+	}{
+		{"matching group", &tenantSessionID, "user", http.StatusOK},
+		{"mismatched group", &otherSessionID, "user", http.StatusForbidden},
+		{"no group", &ungroupedSessionID, "user", http.StatusForbidden},
+		{"no info", &tenantSessionID, nil, http.StatusForbidden},
+		{"unknown session", &unknownSessionID, "user", http.StatusUnauthorized},
+		{"no session", nil, "user", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/resource?tenant=tenant", nil)
+		if test.sessionID != nil {
+			req = withSessionID(req, *test.sessionID)
+		}
+
+		if test.info != nil {
+			req = req.WithContext(context.WithValue(req.Context(), testInfoKey{}, test.info))
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+}
@@ -3,50 +3,137 @@ package httpauth
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
-	"fmt"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/O-C-R/auth/id"
 )
 
 var (
+	InvalidRealmError      = errors.New("invalid realm")
 	basicAuthenticationSep = []byte{':'}
 )
 
+// authorizationCredentials returns the credentials following scheme in an
+// Authorization header value. The scheme is matched case-insensitively and
+// may be followed by any amount of whitespace. Any trailing comma-separated
+// auth-params are ignored.
+func authorizationCredentials(authorization, scheme string) (string, bool) {
+	authorization = strings.TrimSpace(authorization)
+	if len(authorization) <= len(scheme) || !strings.EqualFold(authorization[:len(scheme)], scheme) {
+		return "", false
+	}
+
+	if c := authorization[len(scheme)]; c != ' ' && c != '\t' {
+		return "", false
+	}
+
+	credentials := strings.TrimLeft(authorization[len(scheme)+1:], " \t")
+	if i := strings.IndexAny(credentials, ", \t"); i >= 0 {
+		credentials = credentials[:i]
+	}
+
+	return credentials, credentials != ""
+}
+
+// BearerCredentials returns the token of a Bearer Authorization header, for
+// authentication funcs outside this package.
+func BearerCredentials(req *http.Request) (string, bool) {
+	return authorizationCredentials(req.Header.Get("authorization"), "Bearer")
+}
+
 type AuthenticationFunc func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error)
 
+// AuthObserver is told the outcome of each request an authentication handler
+// serves, for example to count them or log them.
+type AuthObserver interface {
+	// OnSuccess is called with the authenticated request, which carries any
+	// info the authentication func placed in its context.
+	OnSuccess(req *http.Request)
+	OnFailure(req *http.Request)
+	OnError(req *http.Request, err error)
+}
+
+// NopAuthObserver is an AuthObserver that does nothing.
+type NopAuthObserver struct{}
+
+func (NopAuthObserver) OnSuccess(req *http.Request)          {}
+func (NopAuthObserver) OnFailure(req *http.Request)          {}
+func (NopAuthObserver) OnError(req *http.Request, err error) {}
+
+// DefaultAuthObserver observes authentication handlers whose options do not
+// set an Observer, including those made by AuthenticationHandler and
+// AuthenticationFallbackHandler. It is read when each handler is made, so
+// set it before making any; changing it later does not affect existing
+// handlers.
+var DefaultAuthObserver AuthObserver = NopAuthObserver{}
+
+// AuthenticationHandlerOptions customizes the responses of
+// AuthenticationHandlerWithOptions.
+type AuthenticationHandlerOptions struct {
+	// Observer, if not nil, is told the outcome of each request in place of
+	// DefaultAuthObserver as it was when the handler was made.
+	Observer AuthObserver
+
+	// ErrorHandler, if not nil, responds to requests whose authentication
+	// failed with an error, instead of a bare 500 Internal Server Error.
+	ErrorHandler func(w http.ResponseWriter, req *http.Request, err error)
+
+	// UnauthorizedHandler, if not nil, serves requests that are not
+	// authentic, instead of a bare 401 Unauthorized. Any challenge headers
+	// set by the authentication func are already in place.
+	UnauthorizedHandler http.Handler
+}
+
 func AuthenticationHandler(handler http.Handler, authenticationFunc AuthenticationFunc) http.Handler {
+	return AuthenticationHandlerWithOptions(handler, authenticationFunc, AuthenticationHandlerOptions{})
+}
+
+// AuthenticationHandlerWithOptions behaves like AuthenticationHandler, but
+// responds to errors and unauthenticated requests as options specify, for
+// example with a JSON error body.
+func AuthenticationHandlerWithOptions(handler http.Handler, authenticationFunc AuthenticationFunc, options AuthenticationHandlerOptions) http.Handler {
+	observer := options.Observer
+	if observer == nil {
+		observer = DefaultAuthObserver
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		authenticationReq, authentic, err := authenticationFunc(w, req)
 		if err != nil {
+			observer.OnError(req, err)
+			if options.ErrorHandler != nil {
+				options.ErrorHandler(w, req, err)
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
 		if !authentic {
+			observer.OnFailure(authenticationReq)
+			if options.UnauthorizedHandler != nil {
+				options.UnauthorizedHandler.ServeHTTP(w, authenticationReq)
+				return
+			}
+
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
+		observer.OnSuccess(authenticationReq)
 		handler.ServeHTTP(w, authenticationReq)
 	})
 }
 
 func AuthenticationFallbackHandler(handler http.Handler, authenticationFunc AuthenticationFunc, fallbackHandler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		authenticationReq, authentic, err := authenticationFunc(w, req)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		if !authentic {
-			fallbackHandler.ServeHTTP(w, authenticationReq)
-			return
-		}
-
-		handler.ServeHTTP(w, authenticationReq)
+	return AuthenticationHandlerWithOptions(handler, authenticationFunc, AuthenticationHandlerOptions{
+		UnauthorizedHandler: fallbackHandler,
 	})
 }
 
@@ -54,53 +141,122 @@ type UserAuthenticator interface {
 	AuthenticateUser(username, password string) (info interface{}, authentic bool, err error)
 }
 
+// SingleUserAuthenticator authenticates a single username and password. The
+// credentials are compared in constant time, by their SHA-256 digests, so that
+// response times do not reveal how much of a guess was correct or how long
+// the password is. The password is kept in memory; BcryptUserAuthenticator
+// keeps only a hash.
 type SingleUserAuthenticator struct {
-	username, password string
+	usernameDigest, passwordDigest [sha256.Size]byte
 }
 
 func NewSingleUserAuthenticator(username, password string) *SingleUserAuthenticator {
 	return &SingleUserAuthenticator{
-		username: username,
-		password: password,
+		usernameDigest: sha256.Sum256([]byte(username)),
+		passwordDigest: sha256.Sum256([]byte(password)),
 	}
 }
 
 func (s *SingleUserAuthenticator) AuthenticateUser(username, password string) (info interface{}, authentic bool, err error) {
-	if username != s.username || password != s.password {
+	usernameDigest, passwordDigest := sha256.Sum256([]byte(username)), sha256.Sum256([]byte(password))
+
+	// Both comparisons always run, so a wrong username takes as long as a
+	// wrong password.
+	if subtle.ConstantTimeCompare(usernameDigest[:], s.usernameDigest[:])&subtle.ConstantTimeCompare(passwordDigest[:], s.passwordDigest[:]) != 1 {
 		return nil, false, nil
 	}
 
 	return username, true, nil
 }
 
-func BasicAuthentication(realm string, userAuthenticator UserAuthenticator, contextKey interface{}) AuthenticationFunc {
-	authenticateHeader := "Basic realm=\"" + realm + "\""
-	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
-		encodedUsernamePassword := ""
-		if _, err := fmt.Sscanf(req.Header.Get("authorization"), "Basic %s", &encodedUsernamePassword); err != nil {
-			w.Header().Set("www-authenticate", authenticateHeader)
-			return req, false, nil
+// ValidRealm returns InvalidRealmError if realm contains control characters
+// other than tab, which cannot be sent in a challenge. Authentication funcs
+// drop such characters from the realms they send, so use ValidRealm to reject
+// them up front instead.
+func ValidRealm(realm string) error {
+	for i := 0; i < len(realm); i++ {
+		if c := realm[i]; c != '\t' && (c < ' ' || c == 0x7f) {
+			return InvalidRealmError
 		}
+	}
 
-		decodedUsernamePassword, err := base64.StdEncoding.DecodeString(encodedUsernamePassword)
-		if err != nil {
-			w.Header().Set("www-authenticate", authenticateHeader)
-			return req, false, nil
+	return nil
+}
+
+// normalizeRealm returns realm without the control characters ValidRealm
+// rejects.
+func normalizeRealm(realm string) string {
+	if ValidRealm(realm) == nil {
+		return realm
+	}
+
+	normalized := make([]byte, 0, len(realm))
+	for i := 0; i < len(realm); i++ {
+		if c := realm[i]; c == '\t' || (c >= ' ' && c != 0x7f) {
+			normalized = append(normalized, c)
 		}
+	}
 
-		usernamePassword := bytes.SplitN(decodedUsernamePassword, basicAuthenticationSep, 2)
-		if len(usernamePassword) != 2 {
+	return string(normalized)
+}
+
+// quotedString returns s, which must be valid as ValidRealm defines it, as an
+// RFC 7230 quoted-string, escaping quotes and backslashes.
+func quotedString(s string) string {
+	quoted := make([]byte, 0, len(s)+2)
+	quoted = append(quoted, '"')
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			quoted = append(quoted, '\\', c)
+		} else {
+			quoted = append(quoted, c)
+		}
+	}
+
+	return string(append(quoted, '"'))
+}
+
+// basicCredentials returns the user-id and password of Basic credentials in
+// an Authorization header value. The password may contain colons.
+func basicCredentials(authorization string) (string, string, bool) {
+	encodedUsernamePassword, ok := authorizationCredentials(authorization, "Basic")
+	if !ok {
+		return "", "", false
+	}
+
+	decodedUsernamePassword, err := base64.StdEncoding.DecodeString(encodedUsernamePassword)
+	if err != nil {
+		return "", "", false
+	}
+
+	usernamePassword := bytes.SplitN(decodedUsernamePassword, basicAuthenticationSep, 2)
+	if len(usernamePassword) != 2 {
+		return "", "", false
+	}
+
+	return string(usernamePassword[0]), string(usernamePassword[1]), true
+}
+
+// BasicAuthentication authenticates Basic credentials with userAuthenticator.
+// Control characters in realm are dropped from the challenge; see ValidRealm.
+func BasicAuthentication(realm string, userAuthenticator UserAuthenticator, contextKey interface{}) AuthenticationFunc {
+	authenticateHeader := "Basic realm=" + quotedString(normalizeRealm(realm))
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		username, password, ok := basicCredentials(req.Header.Get("authorization"))
+		if !ok {
+			w.Header().Set("www-authenticate", authenticateHeader)
 			return req, false, nil
 		}
 
-		username, password := string(usernamePassword[0]), string(usernamePassword[1])
+		// An error is a failure to authenticate, not a rejection of the
+		// credentials, so the client is not challenged for new ones.
 		info, authentic, err := userAuthenticator.AuthenticateUser(username, password)
 		if err != nil {
-			w.Header().Set("www-authenticate", authenticateHeader)
 			return req, false, err
 		}
 
 		if !authentic {
+			w.Header().Set("www-authenticate", authenticateHeader)
 			return req, false, nil
 		}
 
@@ -131,24 +287,68 @@ func NewSingleTokenAuthenticator(id id.ID) *SingleTokenAuthenticator {
 }
 
 func (s *SingleTokenAuthenticator) AuthenticateToken(id id.ID) (interface{}, bool, error) {
-	if id != s.id {
+	if !id.Equal(s.id) {
 		return nil, false, nil
 	}
 
 	return id, true, nil
 }
 
+// BearerAuthentication authenticates bearer tokens with tokenAuthenticator,
+// challenging rejected requests without a realm. Tokens are also accepted in
+// the access_token query parameter, but not in a form body.
 func BearerAuthentication(tokenAuthenticator TokenAuthenticator, contextKey interface{}) AuthenticationFunc {
+	return bearerAuthentication(nil, "access_token", tokenAuthenticator, contextKey)
+}
+
+// BearerAuthenticationField behaves like BearerAuthentication, but accepts
+// tokens in the query parameter fieldName in place of access_token. If
+// fieldName is empty, only the Authorization header is read.
+func BearerAuthenticationField(tokenAuthenticator TokenAuthenticator, contextKey interface{}, fieldName string) AuthenticationFunc {
+	return bearerAuthentication(nil, fieldName, tokenAuthenticator, contextKey)
+}
+
+// BearerRealmAuthentication behaves like BearerAuthentication, but challenges
+// rejected requests with realm. Control characters in realm are dropped from
+// the challenge; see ValidRealm.
+func BearerRealmAuthentication(realm string, tokenAuthenticator TokenAuthenticator, contextKey interface{}) AuthenticationFunc {
+	return bearerAuthentication([]string{"realm=" + quotedString(normalizeRealm(realm))}, "access_token", tokenAuthenticator, contextKey)
+}
+
+// bearerChallenge returns a Bearer challenge with the given auth-params.
+func bearerChallenge(params ...string) string {
+	if len(params) == 0 {
+		return "Bearer"
+	}
+
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// bearerAuthentication challenges requests without a token with params alone
+// and requests with a rejected token with an RFC 6750 invalid_token error as
+// well, so clients can tell the two apart.
+func bearerAuthentication(params []string, fieldName string, tokenAuthenticator TokenAuthenticator, contextKey interface{}) AuthenticationFunc {
+	authenticateHeader := bearerChallenge(params...)
+	invalidTokenHeader := bearerChallenge(append(params, `error="invalid_token"`)...)
+
 	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
-		tokenString := req.FormValue("access_token")
-		if tokenString == "" {
-			if _, err := fmt.Sscanf(req.Header.Get("authorization"), "Bearer %s", &tokenString); err != nil {
+		tokenString, ok := authorizationCredentials(req.Header.Get("authorization"), "Bearer")
+		if !ok {
+			// Only the query is read, never a form body, so that the body
+			// reaches the handler unread.
+			if fieldName != "" {
+				tokenString = req.URL.Query().Get(fieldName)
+			}
+
+			if tokenString == "" {
+				w.Header().Set("www-authenticate", authenticateHeader)
 				return req, false, nil
 			}
 		}
 
-		var token id.ID
-		if err := token.UnmarshalText([]byte(tokenString)); err != nil {
+		token, ok := parseToken([]byte(tokenString))
+		if !ok {
+			w.Header().Set("www-authenticate", invalidTokenHeader)
 			return req, false, nil
 		}
 
@@ -158,9 +358,11 @@ func BearerAuthentication(tokenAuthenticator TokenAuthenticator, contextKey inte
 		}
 
 		if !authentic {
+			w.Header().Set("www-authenticate", invalidTokenHeader)
 			return req, false, nil
 		}
 
+		req = withSessionID(req, token)
 		if contextKey != nil {
 			ctx := req.Context()
 			ctx = context.WithValue(ctx, contextKey, info)
@@ -175,10 +377,56 @@ func BearerAuthenticationHandler(handler http.Handler, tokenAuthenticator TokenA
 	return AuthenticationHandler(handler, BearerAuthentication(tokenAuthenticator, contextKey))
 }
 
+func BearerAuthenticationFieldHandler(handler http.Handler, tokenAuthenticator TokenAuthenticator, contextKey interface{}, fieldName string) http.Handler {
+	return AuthenticationHandler(handler, BearerAuthenticationField(tokenAuthenticator, contextKey, fieldName))
+}
+
+func BearerRealmAuthenticationHandler(handler http.Handler, realm string, tokenAuthenticator TokenAuthenticator, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, BearerRealmAuthentication(realm, tokenAuthenticator, contextKey))
+}
+
+// BasicTokenAuthentication authenticates tokens sent as the username of Basic
+// credentials with an empty password, as is common for API keys.
+func BasicTokenAuthentication(tokenAuthenticator TokenAuthenticator, contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		username, password, ok := basicCredentials(req.Header.Get("authorization"))
+		if !ok || password != "" {
+			return req, false, nil
+		}
+
+		token, ok := parseToken([]byte(username))
+		if !ok {
+			return req, false, nil
+		}
+
+		info, authentic, err := tokenAuthenticator.AuthenticateToken(token)
+		if err != nil {
+			return req, false, err
+		}
+
+		if !authentic {
+			return req, false, nil
+		}
+
+		req = withSessionID(req, token)
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, info)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func BasicTokenAuthenticationHandler(handler http.Handler, tokenAuthenticator TokenAuthenticator, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, BasicTokenAuthentication(tokenAuthenticator, contextKey))
+}
+
 func TokenHeaderAuthentication(tokenAuthenticator TokenAuthenticator, contextKey interface{}, header string) AuthenticationFunc {
 	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
-		var token id.ID
-		if err := token.UnmarshalText([]byte(req.Header.Get(header))); err != nil {
+		token, ok := parseToken([]byte(req.Header.Get(header)))
+		if !ok {
 			return req, false, nil
 		}
 
@@ -191,6 +439,7 @@ func TokenHeaderAuthentication(tokenAuthenticator TokenAuthenticator, contextKey
 			return req, false, nil
 		}
 
+		req = withSessionID(req, token)
 		if contextKey != nil {
 			ctx := req.Context()
 			ctx = context.WithValue(ctx, contextKey, info)
@@ -204,3 +453,83 @@ func TokenHeaderAuthentication(tokenAuthenticator TokenAuthenticator, contextKey
 func TokenHeaderAuthenticationHandler(handler http.Handler, tokenAuthenticator TokenAuthenticator, contextKey interface{}, header string) http.Handler {
 	return AuthenticationHandler(handler, TokenHeaderAuthentication(tokenAuthenticator, contextKey, header))
 }
+
+// TokenSchemeAuthentication authenticates tokens sent with the Token
+// Authorization scheme, as some clients do in place of Bearer.
+func TokenSchemeAuthentication(tokenAuthenticator TokenAuthenticator, contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		tokenString, ok := authorizationCredentials(req.Header.Get("authorization"), "Token")
+		if !ok {
+			return req, false, nil
+		}
+
+		token, ok := parseToken([]byte(tokenString))
+		if !ok {
+			return req, false, nil
+		}
+
+		info, authentic, err := tokenAuthenticator.AuthenticateToken(token)
+		if err != nil {
+			return req, false, err
+		}
+
+		if !authentic {
+			return req, false, nil
+		}
+
+		req = withSessionID(req, token)
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, info)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func TokenSchemeAuthenticationHandler(handler http.Handler, tokenAuthenticator TokenAuthenticator, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, TokenSchemeAuthentication(tokenAuthenticator, contextKey))
+}
+
+// CookieOrBearerAuthentication authenticates a token from a Bearer
+// Authorization header or, if there is none, from the named cookie.
+func CookieOrBearerAuthentication(cookieName string, tokenAuthenticator TokenAuthenticator, contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		tokenString, ok := authorizationCredentials(req.Header.Get("authorization"), "Bearer")
+		if !ok {
+			cookie, err := req.Cookie(cookieName)
+			if err != nil {
+				return req, false, nil
+			}
+			tokenString = cookie.Value
+		}
+
+		token, ok := parseToken([]byte(tokenString))
+		if !ok {
+			return req, false, nil
+		}
+
+		info, authentic, err := tokenAuthenticator.AuthenticateToken(token)
+		if err != nil {
+			return req, false, err
+		}
+
+		if !authentic {
+			return req, false, nil
+		}
+
+		req = withSessionID(req, token)
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, info)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func CookieOrBearerAuthenticationHandler(handler http.Handler, cookieName string, tokenAuthenticator TokenAuthenticator, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, CookieOrBearerAuthentication(cookieName, tokenAuthenticator, contextKey))
+}
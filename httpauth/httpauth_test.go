@@ -2,9 +2,13 @@ package httpauth
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/O-C-R/auth/id"
@@ -51,6 +55,136 @@ func TestBasicAuthenticationHandler(t *testing.T) {
 	}
 }
 
+func TestBasicAuthenticationCredentials(t *testing.T) {
+	authenticationFunc := BasicAuthentication("test", NewSingleUserAuthenticator("username", "pass: word"), nil)
+
+	for _, test := range []struct {
+		authorization string
+		authentic     bool
+		challenged    bool
+	}{
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("username:pass: word")), true, false},
+		{"basic " + base64.StdEncoding.EncodeToString([]byte("username:pass: word")), true, false},
+		{"BASIC " + base64.StdEncoding.EncodeToString([]byte("username:pass: word")), true, false},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("username:pass")), false, true},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("username")), false, true},
+		{"Basic not-base64", false, true},
+		{"Basically " + base64.StdEncoding.EncodeToString([]byte("username:pass: word")), false, true},
+		{"", false, true},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.authorization != "" {
+			req.Header.Set("authorization", test.authorization)
+		}
+
+		w := httptest.NewRecorder()
+		_, authentic, err := authenticationFunc(w, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authentic != test.authentic {
+			t.Errorf("credentials %q: authentic %t, expected %t", test.authorization, authentic, test.authentic)
+		}
+
+		if challenged := w.Header().Get("www-authenticate") != ""; challenged != test.challenged {
+			t.Errorf("credentials %q: challenged %t, expected %t", test.authorization, challenged, test.challenged)
+		}
+	}
+}
+
+func TestBasicAuthenticationError(t *testing.T) {
+	handler := BasicAuthenticationHandler(http.NotFoundHandler(), "test", failingUserAuthenticator{}, nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("username:password")))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("request with failing authenticator returned status %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+
+	if header := w.Header().Get("www-authenticate"); header != "" {
+		t.Errorf("request with failing authenticator challenged with %s", header)
+	}
+}
+
+type failingUserAuthenticator struct{}
+
+func (failingUserAuthenticator) AuthenticateUser(username, password string) (interface{}, bool, error) {
+	return nil, false, errors.New("user store unavailable")
+}
+
+func TestSingleUserAuthenticator(t *testing.T) {
+	userAuthenticator := NewSingleUserAuthenticator("username", "password")
+
+	for _, test := range []struct {
+		username, password string
+		authentic          bool
+	}{
+		{"username", "password", true},
+		{"username", "passwor", false},
+		{"username", "password1", false},
+		{"username", "", false},
+		{"user", "password", false},
+		{"", "", false},
+	} {
+		info, authentic, err := userAuthenticator.AuthenticateUser(test.username, test.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authentic != test.authentic {
+			t.Errorf("%q:%q: authentic %t, expected %t", test.username, test.password, authentic, test.authentic)
+		}
+
+		if authentic && info != test.username {
+			t.Errorf("%q:%q: incorrect info %v", test.username, test.password, info)
+		}
+	}
+}
+
+func TestBasicAuthenticationRealm(t *testing.T) {
+	authenticationFunc := BasicAuthentication(`a "quoted" \ realm`, NewSingleUserAuthenticator("username", "password"), nil)
+
+	w := httptest.NewRecorder()
+	authenticationFunc(w, httptest.NewRequest("GET", "/", nil))
+
+	if header := w.Header().Get("www-authenticate"); header != `Basic realm="a \"quoted\" \\ realm"` {
+		t.Errorf("incorrect challenge header %s", header)
+	}
+
+	authenticationFunc = BasicAuthentication("realm\r\nx-injected: 1", NewSingleUserAuthenticator("username", "password"), nil)
+
+	w = httptest.NewRecorder()
+	authenticationFunc(w, httptest.NewRequest("GET", "/", nil))
+
+	if header := w.Header().Get("www-authenticate"); header != `Basic realm="realmx-injected: 1"` {
+		t.Errorf("incorrect challenge header %s", header)
+	}
+}
+
+func TestValidRealm(t *testing.T) {
+	for _, test := range []struct {
+		realm string
+		valid bool
+	}{
+		{"realm", true},
+		{`a "quoted" \ realm`, true},
+		{"tab\trealm", true},
+		{"", true},
+		{"realm\r\nx-injected: 1", false},
+		{"realm\x00", false},
+		{"realm\x7f", false},
+	} {
+		if err := ValidRealm(test.realm); (err == nil) != test.valid {
+			t.Errorf("%q: error %v, expected valid %t", test.realm, err, test.valid)
+		}
+	}
+}
+
 func TestBearerAuthenticationHandler(t *testing.T) {
 	token, err := id.New()
 	if err != nil {
@@ -86,13 +220,14 @@ func TestBearerAuthenticationHandler(t *testing.T) {
 		t.Errorf("authenticated request failed with status %d", response.StatusCode)
 	}
 
+	// Form bodies are not read, so that handlers receive the body intact.
 	response, err = http.PostForm(server.URL, url.Values{"access_token": {token.String()}})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if response.StatusCode != http.StatusOK {
-		t.Errorf("authenticated request failed with status %d", response.StatusCode)
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("form body request returned status %d, expected %d", response.StatusCode, http.StatusUnauthorized)
 	}
 
 	request, err := http.NewRequest("GET", server.URL, nil)
@@ -109,6 +244,361 @@ func TestBearerAuthenticationHandler(t *testing.T) {
 	if response.StatusCode != http.StatusOK {
 		t.Errorf("authenticated request failed with status %d", response.StatusCode)
 	}
+
+	request.Header.Set("authorization", "Bearer "+token.String()+", foo=bar")
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("authenticated request with auth-params failed with status %d", response.StatusCode)
+	}
+}
+
+func TestBearerAuthenticationScheme(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticationFunc := BearerAuthentication(NewSingleTokenAuthenticator(token), nil)
+
+	for _, test := range []struct {
+		authorization string
+		authentic     bool
+	}{
+		{"Bearer " + token.String(), true},
+		{"bearer " + token.String(), true},
+		{"BEARER " + token.String(), true},
+		{"Bearer   " + token.String(), true},
+		{"Bearer\t" + token.String(), true},
+		{"  Bearer " + token.String() + "  ", true},
+		{"Bearer " + token.String() + " \t", true},
+		{"Bearer" + token.String(), false},
+		{"Bearers " + token.String(), false},
+		{"Bearer ", false},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header["Authorization"] = []string{test.authorization}
+
+		if _, authentic, err := authenticationFunc(httptest.NewRecorder(), req); err != nil {
+			t.Fatal(err)
+		} else if authentic != test.authentic {
+			t.Errorf("authorization %q: authentic %t, expected %t", test.authorization, authentic, test.authentic)
+		}
+	}
+}
+
+func TestBearerAuthenticationChallenge(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name               string
+		authenticationFunc AuthenticationFunc
+		authorization      string
+		challenge          string
+	}{
+		{"missing", BearerAuthentication(NewSingleTokenAuthenticator(token), nil), "", "Bearer"},
+		{"malformed", BearerAuthentication(NewSingleTokenAuthenticator(token), nil), "Bearer garbage", `Bearer error="invalid_token"`},
+		{"unknown", BearerAuthentication(NewSingleTokenAuthenticator(token), nil), "Bearer " + otherToken.String(), `Bearer error="invalid_token"`},
+		{"valid", BearerAuthentication(NewSingleTokenAuthenticator(token), nil), "Bearer " + token.String(), ""},
+		{"realm missing", BearerRealmAuthentication("test", NewSingleTokenAuthenticator(token), nil), "", `Bearer realm="test"`},
+		{"realm malformed", BearerRealmAuthentication("test", NewSingleTokenAuthenticator(token), nil), "Bearer garbage", `Bearer realm="test", error="invalid_token"`},
+		{"realm unknown", BearerRealmAuthentication("test", NewSingleTokenAuthenticator(token), nil), "Bearer " + otherToken.String(), `Bearer realm="test", error="invalid_token"`},
+		{"realm valid", BearerRealmAuthentication("test", NewSingleTokenAuthenticator(token), nil), "Bearer " + token.String(), ""},
+		{"error", BearerAuthentication(failingTokenAuthenticator{}, nil), "Bearer " + token.String(), ""},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.authorization != "" {
+			req.Header.Set("authorization", test.authorization)
+		}
+
+		w := httptest.NewRecorder()
+		test.authenticationFunc(w, req)
+
+		if challenge := w.Header().Get("www-authenticate"); challenge != test.challenge {
+			t.Errorf("%s: challenge %q, expected %q", test.name, challenge, test.challenge)
+		}
+	}
+}
+
+type failingTokenAuthenticator struct{}
+
+func (failingTokenAuthenticator) AuthenticateToken(token id.ID) (interface{}, bool, error) {
+	return nil, false, errors.New("token store unavailable")
+}
+
+func TestBearerAuthenticationField(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := func(fieldName string) http.Handler {
+		return BearerAuthenticationFieldHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// JSON bodies must reach the handler unread.
+			if req.Header.Get("content-type") == "application/json" {
+				if body, err := io.ReadAll(req.Body); err != nil || string(body) != `{}` {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}), NewSingleTokenAuthenticator(token), nil, fieldName)
+	}
+
+	form := func(fieldName string) func() *http.Request {
+		return func() *http.Request {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(url.Values{fieldName: {token.String()}}.Encode()))
+			req.Header.Set("content-type", "application/x-www-form-urlencoded")
+			return req
+		}
+	}
+
+	for _, test := range []struct {
+		name      string
+		fieldName string
+		req       func() *http.Request
+		status    int
+	}{
+		{"query", "token", func() *http.Request {
+			return httptest.NewRequest("GET", "/?token="+token.String(), nil)
+		}, http.StatusOK},
+		{"default query", "token", func() *http.Request {
+			return httptest.NewRequest("GET", "/?access_token="+token.String(), nil)
+		}, http.StatusUnauthorized},
+		{"form", "api_key", form("api_key"), http.StatusUnauthorized},
+		{"query on post", "token", func() *http.Request {
+			req := httptest.NewRequest("POST", "/?token="+token.String(), strings.NewReader(`{}`))
+			req.Header.Set("content-type", "application/json")
+			return req
+		}, http.StatusOK},
+		{"header on post", "token", func() *http.Request {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(`{}`))
+			req.Header.Set("content-type", "application/json")
+			req.Header.Set("authorization", "Bearer "+token.String())
+			return req
+		}, http.StatusOK},
+		{"json body", "token", func() *http.Request {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(`{"token":"`+token.String()+`"}`))
+			req.Header.Set("content-type", "application/json")
+			return req
+		}, http.StatusUnauthorized},
+		{"disabled query", "", func() *http.Request {
+			return httptest.NewRequest("GET", "/?access_token="+token.String(), nil)
+		}, http.StatusUnauthorized},
+		{"disabled header", "", func() *http.Request {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("authorization", "Bearer "+token.String())
+			return req
+		}, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		handler(test.fieldName).ServeHTTP(w, test.req())
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+}
+
+func TestBearerAuthenticationBody(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := BearerAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if body, err := io.ReadAll(req.Body); err != nil || string(body) != `{"access_token":"body"}` {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), NewSingleTokenAuthenticator(token), nil)
+
+	for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"access_token":"body"}`))
+		req.Header.Set("content-type", contentType)
+		req.Header.Set("authorization", "Bearer "+token.String())
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: request returned status %d, expected %d", contentType, w.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestBearerAuthenticationZeroToken(t *testing.T) {
+	handler := BearerAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), NewSingleTokenAuthenticator(id.Zero), nil)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Header.Set("authorization", "Bearer "+id.Zero.String())
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Error("server allowed request with a zero token")
+	}
+}
+
+func TestBasicTokenAuthenticationHandler(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := BasicTokenAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Value(testInfoKey{}).(id.ID); !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), NewSingleTokenAuthenticator(token), testInfoKey{})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for _, test := range []struct {
+		credentials string
+		status      int
+	}{
+		{token.String() + ":", http.StatusOK},
+		{token.String() + ":password", http.StatusUnauthorized},
+		{token.String(), http.StatusUnauthorized},
+	} {
+		request, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request.Header.Set("authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(test.credentials)))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != test.status {
+			t.Errorf("request with credentials %q returned status %d, expected %d", test.credentials, response.StatusCode, test.status)
+		}
+	}
+}
+
+func TestTokenSchemeAuthenticationHandler(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := TokenSchemeAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if info, ok := req.Context().Value(testInfoKey{}).(id.ID); !ok || info != token {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), NewSingleTokenAuthenticator(token), testInfoKey{})
+
+	for _, test := range []struct {
+		authorization string
+		status        int
+	}{
+		{"Token " + token.String(), http.StatusOK},
+		{"token " + token.String(), http.StatusOK},
+		{"TOKEN\t" + token.String(), http.StatusOK},
+		{"Token " + otherToken.String(), http.StatusUnauthorized},
+		{"Token token", http.StatusUnauthorized},
+		{"Bearer " + token.String(), http.StatusUnauthorized},
+		{"Tokens " + token.String(), http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.authorization != "" {
+			req.Header.Set("authorization", test.authorization)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("authorization %q: request returned status %d, expected %d", test.authorization, w.Code, test.status)
+		}
+	}
+}
+
+func TestCookieOrBearerAuthenticationHandler(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := CookieOrBearerAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Value(testInfoKey{}).(id.ID); !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), "session", NewSingleTokenAuthenticator(token), testInfoKey{})
+
+	for _, test := range []struct {
+		name          string
+		header, value string
+		status        int
+	}{
+		{"header only", token.String(), "", http.StatusOK},
+		{"cookie only", "", token.String(), http.StatusOK},
+		{"both", token.String(), otherToken.String(), http.StatusOK},
+		{"header preferred", otherToken.String(), token.String(), http.StatusUnauthorized},
+		{"neither", "", "", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			req.Header.Set("authorization", "Bearer "+test.header)
+		}
+		if test.value != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: test.value})
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
 }
 
 func TestAuthenticationFallbackHandler(t *testing.T) {
@@ -164,3 +654,206 @@ func TestAuthenticationFallbackHandler(t *testing.T) {
 		t.Error("unauthenticated request not served by the fallback handler")
 	}
 }
+
+func TestAuthenticationHandlerWithOptions(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := AuthenticationHandlerOptions{
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unavailable"})
+		},
+		UnauthorizedHandler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+		}),
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, test := range []struct {
+		name    string
+		handler http.Handler
+		token   string
+		status  int
+		body    map[string]string
+	}{
+		{"unauthorized", AuthenticationHandlerWithOptions(ok, BearerAuthentication(NewSingleTokenAuthenticator(token), nil), options), "", http.StatusUnauthorized, map[string]string{"error": "unauthorized"}},
+		{"error", AuthenticationHandlerWithOptions(ok, BearerAuthentication(failingTokenAuthenticator{}, nil), options), token.String(), http.StatusServiceUnavailable, map[string]string{"error": "unavailable"}},
+		{"authentic", AuthenticationHandlerWithOptions(ok, BearerAuthentication(NewSingleTokenAuthenticator(token), nil), options), token.String(), http.StatusOK, nil},
+		{"default unauthorized", AuthenticationHandlerWithOptions(ok, BearerAuthentication(NewSingleTokenAuthenticator(token), nil), AuthenticationHandlerOptions{}), "", http.StatusUnauthorized, nil},
+		{"default error", AuthenticationHandlerWithOptions(ok, BearerAuthentication(failingTokenAuthenticator{}, nil), AuthenticationHandlerOptions{}), token.String(), http.StatusInternalServerError, nil},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.token != "" {
+			req.Header.Set("authorization", "Bearer "+test.token)
+		}
+
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+
+		if test.body == nil {
+			if w.Body.Len() != 0 {
+				t.Errorf("%s: unexpected body %s", test.name, w.Body)
+			}
+			continue
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if body["error"] != test.body["error"] {
+			t.Errorf("%s: incorrect body %v, expected %v", test.name, body, test.body)
+		}
+	}
+}
+
+func BenchmarkBearerAuthentication(b *testing.B) {
+	token, err := id.New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	authenticationFunc := BearerAuthentication(NewSingleTokenAuthenticator(token), nil)
+
+	request, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	request.Header.Set("authorization", "Bearer "+token.String())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, authentic, _ := authenticationFunc(httptest.NewRecorder(), request); !authentic {
+			b.Fatal("authentication failed")
+		}
+	}
+}
+
+func BenchmarkBasicAuthentication(b *testing.B) {
+	authenticationFunc := BasicAuthentication("test", NewSingleUserAuthenticator("username", "password"), nil)
+
+	request, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	request.Header.Set("authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("username:password")))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, authentic, _ := authenticationFunc(httptest.NewRecorder(), request); !authentic {
+			b.Fatal("authentication failed")
+		}
+	}
+}
+
+// testAuthObserver records the outcomes it is told of.
+type testAuthObserver struct {
+	outcomes []string
+}
+
+func (o *testAuthObserver) OnSuccess(req *http.Request) {
+	if _, ok := req.Context().Value(testInfoKey{}).(id.ID); !ok {
+		o.outcomes = append(o.outcomes, "success without info")
+		return
+	}
+
+	o.outcomes = append(o.outcomes, "success")
+}
+
+func (o *testAuthObserver) OnFailure(req *http.Request) {
+	o.outcomes = append(o.outcomes, "failure")
+}
+
+func (o *testAuthObserver) OnError(req *http.Request, err error) {
+	if err == nil {
+		o.outcomes = append(o.outcomes, "error without error")
+		return
+	}
+
+	o.outcomes = append(o.outcomes, "error")
+}
+
+func TestAuthObserver(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	authenticationFunc := func(tokenAuthenticator TokenAuthenticator) AuthenticationFunc {
+		return BearerAuthentication(tokenAuthenticator, testInfoKey{})
+	}
+
+	serve := func(handler http.Handler, token string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		if token != "" {
+			req.Header.Set("authorization", "Bearer "+token)
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	observer := &testAuthObserver{}
+	options := AuthenticationHandlerOptions{Observer: observer}
+	serve(AuthenticationHandlerWithOptions(ok, authenticationFunc(NewSingleTokenAuthenticator(token)), options), token.String())
+	serve(AuthenticationHandlerWithOptions(ok, authenticationFunc(NewSingleTokenAuthenticator(token)), options), "")
+	serve(AuthenticationHandlerWithOptions(ok, authenticationFunc(failingTokenAuthenticator{}), options), token.String())
+
+	// Handlers made before the default changes keep the old one.
+	earlyHandler := AuthenticationHandler(ok, authenticationFunc(NewSingleTokenAuthenticator(token)))
+
+	defaultObserver := &testAuthObserver{}
+	defer func(observer AuthObserver) {
+		DefaultAuthObserver = observer
+	}(DefaultAuthObserver)
+	DefaultAuthObserver = defaultObserver
+
+	serve(AuthenticationHandler(ok, authenticationFunc(NewSingleTokenAuthenticator(token))), token.String())
+	serve(AuthenticationHandler(ok, authenticationFunc(NewSingleTokenAuthenticator(token))), "")
+	serve(AuthenticationHandler(ok, authenticationFunc(failingTokenAuthenticator{})), token.String())
+	serve(AuthenticationFallbackHandler(ok, authenticationFunc(NewSingleTokenAuthenticator(token)), ok), token.String())
+	serve(AuthenticationFallbackHandler(ok, authenticationFunc(NewSingleTokenAuthenticator(token)), ok), "")
+	serve(earlyHandler, token.String())
+
+	for _, test := range []struct {
+		name     string
+		observer *testAuthObserver
+		outcomes []string
+	}{
+		{"options", observer, []string{"success", "failure", "error"}},
+		{"default", defaultObserver, []string{"success", "failure", "error", "success", "failure"}},
+	} {
+		if len(test.observer.outcomes) != len(test.outcomes) {
+			t.Errorf("%s: outcomes %v, expected %v", test.name, test.observer.outcomes, test.outcomes)
+			continue
+		}
+
+		for i := range test.outcomes {
+			if test.observer.outcomes[i] != test.outcomes[i] {
+				t.Errorf("%s: outcomes %v, expected %v", test.name, test.observer.outcomes, test.outcomes)
+				break
+			}
+		}
+	}
+}
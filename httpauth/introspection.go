@@ -0,0 +1,144 @@
+package httpauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	introspectionCacheDuration = 30 * time.Second
+	introspectionCacheSweepLen = 1024
+)
+
+// IntrospectionClaims are the claims returned by an RFC 7662 token
+// introspection endpoint.
+type IntrospectionClaims struct {
+	Active    bool   `json:"active"`
+	Subject   string `json:"sub"`
+	Scope     string `json:"scope"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type introspectionCacheEntry struct {
+	claims  *IntrospectionClaims
+	expires time.Time
+}
+
+type introspectionCache struct {
+	mu      sync.Mutex
+	entries map[string]introspectionCacheEntry
+}
+
+func (c *introspectionCache) get(token string, now time.Time) (*IntrospectionClaims, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[token]
+	if !ok {
+		return nil, false
+	}
+
+	if !now.Before(entry.expires) {
+		delete(c.entries, token)
+		return nil, false
+	}
+
+	return entry.claims, true
+}
+
+func (c *introspectionCache) set(token string, claims *IntrospectionClaims, now time.Time) {
+	expires := now.Add(introspectionCacheDuration)
+	if claims.ExpiresAt != 0 {
+		if exp := time.Unix(claims.ExpiresAt, 0); exp.Before(expires) {
+			expires = exp
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= introspectionCacheSweepLen {
+		for cachedToken, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, cachedToken)
+			}
+		}
+	}
+
+	c.entries[token] = introspectionCacheEntry{claims: claims, expires: expires}
+}
+
+func introspect(ctx context.Context, endpoint string, clientAuth func(*http.Request), token string) (*IntrospectionClaims, error) {
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(url.Values{"token": {token}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+	req.Header.Set("accept", "application/json")
+	if clientAuth != nil {
+		clientAuth(req)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned status %d", res.StatusCode)
+	}
+
+	claims := &IntrospectionClaims{}
+	if err := json.NewDecoder(res.Body).Decode(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// IntrospectionAuthentication authenticates bearer tokens against an RFC 7662
+// introspection endpoint. clientAuth, if not nil, is applied to each
+// introspection request. Active tokens are cached briefly and their
+// *IntrospectionClaims are placed in the request context.
+func IntrospectionAuthentication(endpoint string, clientAuth func(*http.Request), contextKey interface{}) AuthenticationFunc {
+	cache := &introspectionCache{entries: make(map[string]introspectionCacheEntry)}
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		token, ok := authorizationCredentials(req.Header.Get("authorization"), "Bearer")
+		if !ok {
+			return req, false, nil
+		}
+
+		now := time.Now()
+		claims, ok := cache.get(token, now)
+		if !ok {
+			var err error
+			claims, err = introspect(req.Context(), endpoint, clientAuth, token)
+			if err != nil {
+				return req, false, err
+			}
+
+			if !claims.Active {
+				return req, false, nil
+			}
+
+			cache.set(token, claims, now)
+		}
+
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, claims)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
@@ -0,0 +1,83 @@
+package httpauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIntrospectionAuthentication(t *testing.T) {
+	const (
+		activeToken   = "active"
+		inactiveToken = "inactive"
+		clientSecret  = "secret"
+	)
+
+	requests := 0
+	introspectionServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+
+		if req.Header.Get("x-client-secret") != clientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		claims := IntrospectionClaims{}
+		if req.PostFormValue("token") == activeToken {
+			claims = IntrospectionClaims{Active: true, Subject: "user", Scope: "read"}
+		}
+
+		json.NewEncoder(w).Encode(claims)
+	}))
+	defer introspectionServer.Close()
+
+	authenticationFunc := IntrospectionAuthentication(introspectionServer.URL, func(req *http.Request) {
+		req.Header.Set("x-client-secret", clientSecret)
+	}, testInfoKey{})
+
+	handler := AuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		claims, ok := req.Context().Value(testInfoKey{}).(*IntrospectionClaims)
+		if !ok || claims.Subject != "user" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), authenticationFunc)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for _, test := range []struct {
+		token  string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{inactiveToken, http.StatusUnauthorized},
+		{activeToken, http.StatusOK},
+		{activeToken, http.StatusOK},
+	} {
+		request, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.token != "" {
+			request.Header.Set("authorization", "Bearer "+test.token)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != test.status {
+			t.Errorf("request with token %q returned status %d, expected %d", test.token, response.StatusCode, test.status)
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("expected 2 introspection requests, got %d", requests)
+	}
+}
@@ -0,0 +1,43 @@
+package httpauth
+
+import (
+	"net"
+	"net/http"
+)
+
+type SessionIPChecker interface {
+	CheckSessionIP(sessionID interface{}, ip net.IP) (bool, error)
+}
+
+// SessionIPHandler serves requests with handler only if the client IP may use
+// the request's session, as returned by sessionIDFunc. It should wrap an
+// authentication handler. The client IP is taken from the connection's remote
+// address; forwarding headers are not trusted.
+func SessionIPHandler(handler http.Handler, sessionIPChecker SessionIPChecker, sessionIDFunc func(req *http.Request) (interface{}, bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sessionID, ok := sessionIDFunc(req)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		ip := net.ParseIP(remoteHost(req))
+		if ip == nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		allowed, err := sessionIPChecker.CheckSessionIP(sessionID, ip)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !allowed {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,50 @@
+package httpauth
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testSessionIPChecker struct {
+	allowed *net.IPNet
+}
+
+func (c testSessionIPChecker) CheckSessionIP(sessionID interface{}, ip net.IP) (bool, error) {
+	return c.allowed.Contains(ip), nil
+}
+
+func TestSessionIPHandler(t *testing.T) {
+	for _, test := range []struct {
+		cidr   string
+		status int
+	}{
+		{"127.0.0.0/8", http.StatusOK},
+		{"192.0.2.0/24", http.StatusForbidden},
+	} {
+		_, allowed, err := net.ParseCIDR(test.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler := SessionIPHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}), testSessionIPChecker{allowed}, func(req *http.Request) (interface{}, bool) {
+			return "session", true
+		})
+
+		server := httptest.NewServer(handler)
+
+		response, err := http.DefaultClient.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != test.status {
+			t.Errorf("request bound to %s returned status %d, expected %d", test.cidr, response.StatusCode, test.status)
+		}
+
+		server.Close()
+	}
+}
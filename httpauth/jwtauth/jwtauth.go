@@ -0,0 +1,67 @@
+// Package jwtauth authenticates HTTP requests carrying JSON Web Tokens as
+// bearer tokens. It is separate from httpauth so that only users of JWTs
+// depend on a JWT library.
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/O-C-R/auth/httpauth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	NoValidMethodsError = errors.New("no valid signing methods")
+)
+
+// JWTAuthentication authenticates requests whose Bearer token is a JWT signed
+// with one of validMethods, such as "RS256", with a signature verified by the
+// key keyFunc returns, placing its jwt.MapClaims in the request context.
+// Naming the methods rules out algorithm confusion, where a token signed with
+// an unexpected method is checked with a key meant for another; if
+// validMethods is empty, every request fails with NoValidMethodsError.
+//
+// Expiry and not-before times are checked when present, allowing
+// httpauth.ClockSkew as it is when JWTAuthentication is called. options can
+// require them and check the issuer and audience, for example
+// jwt.WithExpirationRequired(), jwt.WithIssuer and jwt.WithAudience, and are
+// applied after the defaults, so jwt.WithLeeway overrides the skew.
+//
+// Rejected requests are challenged as RFC 6750 describes.
+func JWTAuthentication(keyFunc jwt.Keyfunc, validMethods []string, contextKey interface{}, options ...jwt.ParserOption) httpauth.AuthenticationFunc {
+	if len(validMethods) == 0 {
+		return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+			return req, false, NoValidMethodsError
+		}
+	}
+
+	defaults := []jwt.ParserOption{jwt.WithLeeway(httpauth.ClockSkew), jwt.WithValidMethods(validMethods)}
+	parser := jwt.NewParser(append(defaults, options...)...)
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		tokenString, ok := httpauth.BearerCredentials(req)
+		if !ok {
+			w.Header().Set("www-authenticate", "Bearer")
+			return req, false, nil
+		}
+
+		claims := jwt.MapClaims{}
+		if _, err := parser.ParseWithClaims(tokenString, claims, keyFunc); err != nil {
+			w.Header().Set("www-authenticate", `Bearer error="invalid_token"`)
+			return req, false, nil
+		}
+
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, claims)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func JWTAuthenticationHandler(handler http.Handler, keyFunc jwt.Keyfunc, validMethods []string, contextKey interface{}, options ...jwt.ParserOption) http.Handler {
+	return httpauth.AuthenticationHandler(handler, JWTAuthentication(keyFunc, validMethods, contextKey, options...))
+}
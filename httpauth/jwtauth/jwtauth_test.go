@@ -0,0 +1,92 @@
+package jwtauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/httpauth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testClaimsKey struct{}
+
+func TestJWTAuthentication(t *testing.T) {
+	key := []byte("secret")
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}
+
+	handler := JWTAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		claims, ok := req.Context().Value(testClaimsKey{}).(jwt.MapClaims)
+		if !ok || claims["sub"] != "user" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), keyFunc, []string{"HS256"}, testClaimsKey{}, jwt.WithIssuer("issuer"), jwt.WithAudience("audience"), jwt.WithExpirationRequired())
+
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return token
+	}
+
+	claims := func(iss, aud string, exp time.Time) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "user", "iss": iss, "aud": aud, "exp": exp.Unix()}
+	}
+
+	valid := claims("issuer", "audience", time.Now().Add(time.Hour))
+	unsigned := sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid)
+
+	for _, test := range []struct {
+		name      string
+		token     string
+		status    int
+		challenge string
+	}{
+		{"valid", sign(jwt.SigningMethodHS256, key, valid), http.StatusOK, ""},
+		{"missing", "", http.StatusUnauthorized, "Bearer"},
+		{"expired", sign(jwt.SigningMethodHS256, key, claims("issuer", "audience", time.Now().Add(-time.Hour))), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"expired within skew", sign(jwt.SigningMethodHS256, key, claims("issuer", "audience", time.Now().Add(-httpauth.ClockSkew/2))), http.StatusOK, ""},
+		{"expired beyond skew", sign(jwt.SigningMethodHS256, key, claims("issuer", "audience", time.Now().Add(-2*httpauth.ClockSkew))), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"no expiry", sign(jwt.SigningMethodHS256, key, jwt.MapClaims{"sub": "user", "iss": "issuer", "aud": "audience"}), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"wrong key", sign(jwt.SigningMethodHS256, []byte("other"), valid), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"wrong issuer", sign(jwt.SigningMethodHS256, key, claims("other", "audience", time.Now().Add(time.Hour))), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"wrong audience", sign(jwt.SigningMethodHS256, key, claims("issuer", "other", time.Now().Add(time.Hour))), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"wrong method", sign(jwt.SigningMethodHS512, key, valid), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"unsigned", unsigned, http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"garbage", "garbage", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.token != "" {
+			req.Header.Set("authorization", "Bearer "+test.token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+
+		if challenge := w.Header().Get("www-authenticate"); challenge != test.challenge {
+			t.Errorf("%s: challenge %q, expected %q", test.name, challenge, test.challenge)
+		}
+	}
+}
+
+func TestJWTAuthenticationNoValidMethods(t *testing.T) {
+	authenticationFunc := JWTAuthentication(func(token *jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}, nil, nil)
+
+	if _, authentic, err := authenticationFunc(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil)); err != NoValidMethodsError || authentic {
+		t.Errorf("authentic %t, error %v, expected NoValidMethodsError", authentic, err)
+	}
+}
@@ -0,0 +1,112 @@
+package httpauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+var (
+	// ClockSkew is the tolerance allowed for clock differences between
+	// servers when checking whether a token has expired, or whether a signed
+	// request is fresh.
+	ClockSkew = time.Minute
+
+	magicLinkSep = []byte{'.'}
+)
+
+type NonceConsumer interface {
+	ConsumeNonce(nonce string, ttl time.Duration) (ok bool, err error)
+}
+
+// MagicLinkClaims are the claims signed into a magic link token.
+type MagicLinkClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     id.ID  `json:"nonce"`
+}
+
+func magicLinkSignature(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// IssueMagicLink returns a single-use token for subject that expires after
+// ttl, for use as the token query parameter of a login link.
+func IssueMagicLink(secret []byte, subject string, ttl time.Duration) (string, error) {
+	nonce, err := id.New()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(MagicLinkClaims{
+		Subject:   subject,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+		Nonce:     nonce,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(magicLinkSignature(secret, payload)), nil
+}
+
+// MagicLinkAuthentication authenticates requests carrying a token issued by
+// IssueMagicLink in the token query parameter. Each token is accepted once;
+// nonceConsumer records used tokens. onSuccess is called with the token's
+// claims to establish a session.
+func MagicLinkAuthentication(secret []byte, nonceConsumer NonceConsumer, onSuccess func(w http.ResponseWriter, req *http.Request, claims *MagicLinkClaims) error) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		encodedPayloadSignature := bytes.SplitN([]byte(req.URL.Query().Get("token")), magicLinkSep, 2)
+		if len(encodedPayloadSignature) != 2 {
+			return req, false, nil
+		}
+
+		payload, err := base64.RawURLEncoding.DecodeString(string(encodedPayloadSignature[0]))
+		if err != nil {
+			return req, false, nil
+		}
+
+		signature, err := base64.RawURLEncoding.DecodeString(string(encodedPayloadSignature[1]))
+		if err != nil {
+			return req, false, nil
+		}
+
+		if !hmac.Equal(signature, magicLinkSignature(secret, payload)) {
+			return req, false, nil
+		}
+
+		claims := &MagicLinkClaims{}
+		if err := json.Unmarshal(payload, claims); err != nil {
+			return req, false, nil
+		}
+
+		// The nonce is kept for as long as the token would be accepted.
+		remaining := time.Until(time.Unix(claims.ExpiresAt, 0)) + ClockSkew
+		if remaining <= 0 {
+			return req, false, nil
+		}
+
+		ok, err := nonceConsumer.ConsumeNonce(claims.Nonce.String(), remaining)
+		if err != nil {
+			return req, false, err
+		}
+
+		if !ok {
+			return req, false, nil
+		}
+
+		if err := onSuccess(w, req, claims); err != nil {
+			return req, false, err
+		}
+
+		return req, true, nil
+	}
+}
@@ -0,0 +1,85 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testNonceConsumer struct {
+	mu     sync.Mutex
+	nonces map[string]bool
+}
+
+func (c *testNonceConsumer) ConsumeNonce(nonce string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.nonces[nonce] {
+		return false, nil
+	}
+
+	c.nonces[nonce] = true
+	return true, nil
+}
+
+func TestMagicLinkAuthentication(t *testing.T) {
+	secret := []byte("secret")
+
+	handler := AuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), MagicLinkAuthentication(secret, &testNonceConsumer{nonces: map[string]bool{}}, func(w http.ResponseWriter, req *http.Request, claims *MagicLinkClaims) error {
+		if claims.Subject != "user@example.com" {
+			t.Errorf("incorrect subject, %s", claims.Subject)
+		}
+
+		w.Header().Set("x-subject", claims.Subject)
+		return nil
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	token, err := IssueMagicLink(secret, "user@example.com", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skewedToken, err := IssueMagicLink(secret, "user@example.com", -30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiredToken, err := IssueMagicLink(secret, "user@example.com", -2*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forgedToken, err := IssueMagicLink([]byte("other"), "user@example.com", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name, token string
+		status      int
+	}{
+		{"valid", token, http.StatusOK},
+		{"replayed", token, http.StatusUnauthorized},
+		{"skewed", skewedToken, http.StatusOK},
+		{"expired", expiredToken, http.StatusUnauthorized},
+		{"forged", forgedToken, http.StatusUnauthorized},
+	} {
+		response, err := http.DefaultClient.Get(server.URL + "?token=" + url.QueryEscape(test.token))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != test.status {
+			t.Errorf("%s link returned status %d, expected %d", test.name, response.StatusCode, test.status)
+		}
+	}
+}
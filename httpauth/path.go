@@ -0,0 +1,71 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// LastPathSegment extracts the last segment of a path, for use with
+// PathTokenAuthentication.
+func LastPathSegment(path string) (string, bool) {
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 || i == len(path)-1 {
+		return "", false
+	}
+
+	return path[i+1:], true
+}
+
+// PathTokenAuthentication authenticates a token that extract finds in the
+// request path, for clients that cannot set headers.
+//
+// Tokens in paths end up in access logs, proxy logs and browser history. If
+// the token is the last segment of the path, it is removed from the URL of
+// the authenticated request, so that handlers and logging wrapped by this
+// one do not see it; logging that wraps this handler still does, and should
+// be configured to omit paths for these routes.
+func PathTokenAuthentication(extract func(path string) (string, bool), tokenAuthenticator TokenAuthenticator, contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		tokenString, ok := extract(req.URL.Path)
+		if !ok {
+			return req, false, nil
+		}
+
+		token, ok := parseToken([]byte(tokenString))
+		if !ok {
+			return req, false, nil
+		}
+
+		info, authentic, err := tokenAuthenticator.AuthenticateToken(token)
+		if err != nil {
+			return req, false, err
+		}
+
+		if !authentic {
+			return req, false, nil
+		}
+
+		req = withSessionID(req, token)
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, info)
+			req = req.WithContext(ctx)
+		}
+
+		if strings.HasSuffix(req.URL.Path, "/"+tokenString) {
+			// WithContext copies the request but shares its URL.
+			u := *req.URL
+			u.Path = strings.TrimSuffix(u.Path, tokenString)
+			u.RawPath = ""
+			req.URL = &u
+			req.RequestURI = u.RequestURI()
+		}
+
+		return req, true, nil
+	}
+}
+
+func PathTokenAuthenticationHandler(handler http.Handler, extract func(path string) (string, bool), tokenAuthenticator TokenAuthenticator, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, PathTokenAuthentication(extract, tokenAuthenticator, contextKey))
+}
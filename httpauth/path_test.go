@@ -0,0 +1,59 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestPathTokenAuthenticationHandler(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := PathTokenAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Value(testInfoKey{}).(id.ID); !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if req.URL.Path != "/api/v1/resource/" || req.RequestURI != "/api/v1/resource/?q=1" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), LastPathSegment, NewSingleTokenAuthenticator(token), testInfoKey{})
+
+	for _, test := range []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"present", "/api/v1/resource/" + token.String(), http.StatusOK},
+		{"other token", "/api/v1/resource/" + otherToken.String(), http.StatusUnauthorized},
+		{"malformed", "/api/v1/resource/not-a-token", http.StatusUnauthorized},
+		{"zero", "/api/v1/resource/" + id.ID{}.String(), http.StatusUnauthorized},
+		{"missing", "/api/v1/resource/", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", test.path+"?q=1", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+
+		if test.status == http.StatusOK && req.URL.Path != test.path {
+			t.Errorf("%s: original request path modified to %s", test.name, req.URL.Path)
+		}
+	}
+}
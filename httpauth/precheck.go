@@ -0,0 +1,54 @@
+package httpauth
+
+import (
+	"sync/atomic"
+
+	"github.com/O-C-R/auth/id"
+)
+
+// shedTokens counts tokens rejected before reaching a TokenAuthenticator.
+var shedTokens uint64
+
+// ShedTokenCount returns the number of presented tokens rejected without a
+// store lookup, because they were malformed or failed a precheck.
+func ShedTokenCount() uint64 {
+	return atomic.LoadUint64(&shedTokens)
+}
+
+// parseToken parses a presented token, counting it as shed if it is
+// malformed. Absent tokens are not counted.
+func parseToken(text []byte) (id.ID, bool) {
+	var token id.ID
+	if len(text) == 0 {
+		return token, false
+	}
+
+	if err := token.UnmarshalText(text); err != nil || token.IsZero() {
+		atomic.AddUint64(&shedTokens, 1)
+		return token, false
+	}
+
+	return token, true
+}
+
+type precheckedTokenAuthenticator struct {
+	precheck           func(id.ID) bool
+	tokenAuthenticator TokenAuthenticator
+}
+
+// NewPrecheckedTokenAuthenticator returns a TokenAuthenticator that rejects
+// tokens failing precheck, such as a signature check, without calling
+// tokenAuthenticator. precheck should be cheap relative to the lookup it
+// guards.
+func NewPrecheckedTokenAuthenticator(precheck func(id.ID) bool, tokenAuthenticator TokenAuthenticator) TokenAuthenticator {
+	return &precheckedTokenAuthenticator{precheck, tokenAuthenticator}
+}
+
+func (p *precheckedTokenAuthenticator) AuthenticateToken(token id.ID) (interface{}, bool, error) {
+	if !p.precheck(token) {
+		atomic.AddUint64(&shedTokens, 1)
+		return nil, false, nil
+	}
+
+	return p.tokenAuthenticator.AuthenticateToken(token)
+}
@@ -0,0 +1,103 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/O-C-R/auth/id"
+)
+
+// countingTokenAuthenticator counts the tokens that reach it.
+type countingTokenAuthenticator struct {
+	TokenAuthenticator
+	lookups int
+}
+
+func (c *countingTokenAuthenticator) AuthenticateToken(token id.ID) (interface{}, bool, error) {
+	c.lookups++
+	return c.TokenAuthenticator.AuthenticateToken(token)
+}
+
+func TestTokenPrecheck(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejectedToken, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := &countingTokenAuthenticator{TokenAuthenticator: NewSingleTokenAuthenticator(token)}
+	authenticationFunc := BearerAuthentication(NewPrecheckedTokenAuthenticator(func(token id.ID) bool {
+		return token != rejectedToken
+	}, counter), nil)
+
+	for _, test := range []struct {
+		name          string
+		authorization string
+		authentic     bool
+		lookups, shed int
+	}{
+		{"valid", "Bearer " + token.String(), true, 1, 0},
+		{"absent", "", false, 0, 0},
+		{"malformed", "Bearer garbage", false, 0, 1},
+		{"short", "Bearer " + token.String()[:39], false, 0, 1},
+		{"zero", "Bearer " + id.ID{}.String(), false, 0, 1},
+		{"prechecked", "Bearer " + rejectedToken.String(), false, 0, 1},
+	} {
+		counter.lookups = 0
+		shed := ShedTokenCount()
+
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.authorization != "" {
+			req.Header.Set("authorization", test.authorization)
+		}
+
+		if _, authentic, err := authenticationFunc(httptest.NewRecorder(), req); err != nil {
+			t.Fatal(err)
+		} else if authentic != test.authentic {
+			t.Errorf("%s: authentic %t, expected %t", test.name, authentic, test.authentic)
+		}
+
+		if counter.lookups != test.lookups {
+			t.Errorf("%s: %d store lookups, expected %d", test.name, counter.lookups, test.lookups)
+		}
+
+		if n := int(ShedTokenCount() - shed); n != test.shed {
+			t.Errorf("%s: %d tokens shed, expected %d", test.name, n, test.shed)
+		}
+	}
+}
+
+func BenchmarkBearerAuthenticationMalformed(b *testing.B) {
+	token, err := id.New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	counter := &countingTokenAuthenticator{TokenAuthenticator: NewSingleTokenAuthenticator(token)}
+	authenticationFunc := BearerAuthentication(counter, nil)
+
+	request, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	request.Header.Set("authorization", "Bearer "+token.String()[:39]+"x")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, authentic, _ := authenticationFunc(httptest.NewRecorder(), request); authentic {
+			b.Fatal("malformed token authenticated")
+		}
+	}
+	b.StopTimer()
+
+	if counter.lookups != 0 {
+		b.Errorf("%d store lookups for malformed tokens", counter.lookups)
+	}
+}
@@ -0,0 +1,35 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+type proxyInfoKey struct{}
+
+// ReverseProxyHandler returns a handler that authenticates requests with
+// BearerAuthentication and proxies them to target. Inbound credentials are
+// removed before proxying, and injectIdentity is called with the outbound
+// request and the authenticated info so that it can set identity headers.
+func ReverseProxyHandler(target *url.URL, tokenAuthenticator TokenAuthenticator, injectIdentity func(req *http.Request, info interface{})) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+
+		req.Header.Del("authorization")
+
+		query := req.URL.Query()
+		if _, ok := query["access_token"]; ok {
+			query.Del("access_token")
+			req.URL.RawQuery = query.Encode()
+		}
+
+		if injectIdentity != nil {
+			injectIdentity(req, req.Context().Value(proxyInfoKey{}))
+		}
+	}
+
+	return BearerAuthenticationHandler(proxy, tokenAuthenticator, proxyInfoKey{})
+}
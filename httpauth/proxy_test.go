@@ -0,0 +1,82 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestReverseProxyHandler(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if req.URL.Query().Get("access_token") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if req.Header.Get("x-identity") != token.String() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := ReverseProxyHandler(target, NewSingleTokenAuthenticator(token), func(req *http.Request, info interface{}) {
+		req.Header.Set("x-identity", info.(id.ID).String())
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.DefaultClient.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Error("server allowed unauthenticated request")
+	}
+
+	request, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Header.Set("authorization", "Bearer "+token.String())
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("authenticated request failed with status %d", response.StatusCode)
+	}
+
+	response, err = http.DefaultClient.Get(server.URL + "?access_token=" + url.QueryEscape(token.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("authenticated request failed with status %d", response.StatusCode)
+	}
+}
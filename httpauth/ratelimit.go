@@ -0,0 +1,58 @@
+package httpauth
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RateLimiter interface {
+	AllowRetryAfter(client string, rate, capacity float64) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// remoteHost returns the host of the connection's remote address.
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// RemoteIPKey is a RateLimitHandler key func that limits each client IP, as
+// taken from the connection's remote address. Combine it with req.URL.Path to
+// limit each client per route.
+func RemoteIPKey(req *http.Request) string {
+	return remoteHost(req)
+}
+
+// RateLimitHandler serves requests with handler while the token bucket for
+// the key returned by keyFunc, refilling at rate tokens per nanosecond up to
+// capacity, allows them. Other requests are refused with 429 Too Many
+// Requests and a Retry-After header.
+func RateLimitHandler(handler http.Handler, limiter RateLimiter, keyFunc func(*http.Request) string, rate, capacity float64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		allowed, retryAfter, err := limiter.AllowRetryAfter(keyFunc(req), rate, capacity)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !allowed {
+			// Retry-After is in whole seconds, so round up.
+			seconds := int64(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+
+			w.Header().Set("retry-after", strconv.FormatInt(seconds, 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
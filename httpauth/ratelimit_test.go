@@ -0,0 +1,72 @@
+package httpauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testRateLimiter allows a fixed number of requests per key.
+type testRateLimiter struct {
+	counts map[string]int
+	err    error
+}
+
+func (l *testRateLimiter) AllowRetryAfter(client string, rate, capacity float64) (bool, time.Duration, error) {
+	if l.err != nil {
+		return false, 0, l.err
+	}
+
+	l.counts[client]++
+	if float64(l.counts[client]) > capacity {
+		return false, time.Duration(1 / rate), nil
+	}
+
+	return true, 0, nil
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	limiter := &testRateLimiter{counts: make(map[string]int)}
+	handler := RateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), limiter, func(req *http.Request) string {
+		return RemoteIPKey(req) + req.URL.Path
+	}, 1/float64(90*time.Second), 2)
+
+	for _, test := range []struct {
+		remoteAddr, path string
+		status           int
+		retryAfter       string
+	}{
+		{"192.0.2.1:1234", "/a", http.StatusOK, ""},
+		{"192.0.2.1:1235", "/a", http.StatusOK, ""},
+		{"192.0.2.1:1236", "/a", http.StatusTooManyRequests, "90"},
+		{"192.0.2.1:1237", "/b", http.StatusOK, ""},
+		{"192.0.2.2:1234", "/a", http.StatusOK, ""},
+	} {
+		req := httptest.NewRequest("GET", test.path, nil)
+		req.RemoteAddr = test.remoteAddr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("request from %s to %s returned status %d, expected %d", test.remoteAddr, test.path, w.Code, test.status)
+		}
+
+		if retryAfter := w.Header().Get("retry-after"); retryAfter != test.retryAfter {
+			t.Errorf("request from %s to %s returned retry-after %q, expected %q", test.remoteAddr, test.path, retryAfter, test.retryAfter)
+		}
+	}
+
+	limiter.err = errors.New("limiter unavailable")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/a", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("request with failing limiter returned status %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,52 @@
+package httpauth
+
+import (
+	"net/http"
+)
+
+// Scoper is implemented by authentication info that carries scopes or roles.
+type Scoper interface {
+	Scopes() []string
+}
+
+// infoScopes returns the scopes of authentication info, which may be a
+// Scoper or a []string.
+func infoScopes(info interface{}) []string {
+	switch info := info.(type) {
+	case Scoper:
+		return info.Scopes()
+	case []string:
+		return info
+	}
+
+	return nil
+}
+
+// RequireScopes serves requests with handler only if the authentication info
+// stored under contextKey has every one of scopes. The info must be a Scoper
+// or a []string; other types have no scopes. It should wrap an
+// authentication handler. Requests without info are unauthorized, and those
+// missing a scope are forbidden.
+func RequireScopes(handler http.Handler, contextKey interface{}, scopes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info := req.Context().Value(contextKey)
+		if info == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		granted := make(map[string]bool)
+		for _, scope := range infoScopes(info) {
+			granted[scope] = true
+		}
+
+		for _, scope := range scopes {
+			if !granted[scope] {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
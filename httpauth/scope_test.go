@@ -0,0 +1,46 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testScoper []string
+
+func (s testScoper) Scopes() []string {
+	return s
+}
+
+func TestRequireScopes(t *testing.T) {
+	handler := RequireScopes(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), testInfoKey{}, "read", "write")
+
+	for _, test := range []struct {
+		name   string
+		info   interface{}
+		status int
+	}{
+		{"scoper", testScoper{"read", "write"}, http.StatusOK},
+		{"slice", []string{"write", "read", "admin"}, http.StatusOK},
+		{"insufficient scoper", testScoper{"read"}, http.StatusForbidden},
+		{"insufficient slice", []string{"write"}, http.StatusForbidden},
+		{"no scopes", []string{}, http.StatusForbidden},
+		{"other type", "read write", http.StatusForbidden},
+		{"unauthenticated", nil, http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.info != nil {
+			req = req.WithContext(context.WithValue(req.Context(), testInfoKey{}, test.info))
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/O-C-R/auth/session"
+)
+
+// SessionCookieAuthentication authenticates requests whose named cookie holds
+// the ID of a session in store. The session is decoded into the value
+// newSession returns, which must be a pointer, and that pointer is placed in
+// the request context. Missing, malformed and expired sessions are not
+// authenticated.
+func SessionCookieAuthentication(store session.Store, cookieName string, newSession func() interface{}, contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		cookie, err := req.Cookie(cookieName)
+		if err != nil {
+			return req, false, nil
+		}
+
+		sessionID, ok := parseToken([]byte(cookie.Value))
+		if !ok {
+			return req, false, nil
+		}
+
+		sessionValue := newSession()
+		if err := store.Session(sessionID, sessionValue); err != nil {
+			if err == session.NoSessionFoundError {
+				return req, false, nil
+			}
+
+			return req, false, err
+		}
+
+		req = withSessionID(req, sessionID)
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, sessionValue)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func SessionCookieAuthenticationHandler(handler http.Handler, store session.Store, cookieName string, newSession func() interface{}, contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, SessionCookieAuthentication(store, cookieName, newSession, contextKey))
+}
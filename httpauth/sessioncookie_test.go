@@ -0,0 +1,132 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/O-C-R/auth/session"
+)
+
+func TestSessionCookieAuthenticationHandler(t *testing.T) {
+	store := session.NewMemoryStore(session.SessionStoreOptions{
+		SessionDuration: 100 * time.Millisecond,
+	})
+	defer store.Close()
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SetSession(sessionID, "group", "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	unknownID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := SessionCookieAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, ok := req.Context().Value(testInfoKey{}).(*string)
+		if !ok || *user != "user" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if contextID, ok := SessionIDFromContext(req.Context()); !ok || contextID != sessionID {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), store, "session", func() interface{} {
+		return new(string)
+	}, testInfoKey{})
+
+	request := func(value string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if value != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: value})
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, test := range []struct {
+		name   string
+		value  string
+		status int
+	}{
+		{"valid", sessionID.String(), http.StatusOK},
+		{"missing", "", http.StatusUnauthorized},
+		{"malformed", "session", http.StatusUnauthorized},
+		{"unknown", unknownID.String(), http.StatusUnauthorized},
+	} {
+		if status := request(test.value); status != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, status, test.status)
+		}
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if status := request(sessionID.String()); status != http.StatusUnauthorized {
+		t.Errorf("expired: request returned status %d, expected %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestBearerAuthenticationTokenStore(t *testing.T) {
+	store := session.NewMemoryStore(session.SessionStoreOptions{
+		SessionDuration: time.Minute,
+	})
+	defer store.Close()
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SetSession(sessionID, "group", "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	unknownID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := BearerAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, ok := req.Context().Value(testInfoKey{}).(*string)
+		if !ok || *user != "user" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), session.NewTokenStore(store, func() interface{} {
+		return new(string)
+	}), testInfoKey{})
+
+	for _, test := range []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid", sessionID.String(), http.StatusOK},
+		{"unknown", unknownID.String(), http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("authorization", "Bearer "+test.token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+}
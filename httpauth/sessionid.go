@@ -0,0 +1,30 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/O-C-R/auth/id"
+)
+
+type sessionIDKey struct{}
+
+// withSessionID returns req with sessionID stored in its context.
+func withSessionID(req *http.Request, sessionID id.ID) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), sessionIDKey{}, sessionID))
+}
+
+// SessionIDFromContext returns the token that authenticated a request, as
+// stored by the token authentication funcs. When tokens are session IDs,
+// this lets handlers call session store methods on the current session.
+func SessionIDFromContext(ctx context.Context) (id.ID, bool) {
+	sessionID, ok := ctx.Value(sessionIDKey{}).(id.ID)
+	return sessionID, ok
+}
+
+// InfoFromContext returns the authentication info stored under key by an
+// authentication func, if it is present and of type T.
+func InfoFromContext[T any](ctx context.Context, key interface{}) (T, bool) {
+	info, ok := ctx.Value(key).(T)
+	return info, ok
+}
@@ -0,0 +1,57 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestSessionIDFromContext(t *testing.T) {
+	token, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := BearerAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sessionID, ok := SessionIDFromContext(req.Context())
+		if !ok || sessionID != token {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), NewSingleTokenAuthenticator(token), nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("authorization", "Bearer "+token.String())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("session ID not available to handler, status %d", w.Code)
+	}
+
+	if _, ok := SessionIDFromContext(httptest.NewRequest("GET", "/", nil).Context()); ok {
+		t.Error("unexpected session ID in unauthenticated context")
+	}
+}
+
+func TestInfoFromContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), testInfoKey{}, "user")
+
+	if info, ok := InfoFromContext[string](ctx, testInfoKey{}); !ok || info != "user" {
+		t.Errorf("incorrect info %q, %t", info, ok)
+	}
+
+	if info, ok := InfoFromContext[int](ctx, testInfoKey{}); ok || info != 0 {
+		t.Errorf("expected no info of the wrong type, got %d", info)
+	}
+
+	if info, ok := InfoFromContext[string](context.Background(), testInfoKey{}); ok || info != "" {
+		t.Errorf("expected no info for an absent key, got %q", info)
+	}
+}
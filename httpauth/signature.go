@@ -0,0 +1,136 @@
+package httpauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// canonicalRequest returns the string signed for req: the method, the
+// request URI (escaped path and raw query), the Date header and the
+// hex-encoded SHA-256 digest of the body, each followed by a newline.
+func canonicalRequest(req *http.Request, body []byte) []byte {
+	bodyDigest := sha256.Sum256(body)
+
+	var canonical bytes.Buffer
+	canonical.WriteString(req.Method)
+	canonical.WriteByte('\n')
+	canonical.WriteString(req.URL.RequestURI())
+	canonical.WriteByte('\n')
+	canonical.WriteString(req.Header.Get("date"))
+	canonical.WriteByte('\n')
+	canonical.WriteString(hex.EncodeToString(bodyDigest[:]))
+	canonical.WriteByte('\n')
+	return canonical.Bytes()
+}
+
+func requestSignature(secret, canonical []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(canonical)
+	return mac.Sum(nil)
+}
+
+// readBody reads req's body and replaces it so that it can be read again.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// SignRequest signs req with secret for HMACAuthentication, setting its Date
+// header if it has none and an Authorization header of the form
+// "HMAC <keyID>:<base64 signature>". keyID must not contain a colon.
+func SignRequest(req *http.Request, keyID string, secret []byte) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+
+	if req.Header.Get("date") == "" {
+		req.Header.Set("date", time.Now().UTC().Format(http.TimeFormat))
+	}
+
+	signature := requestSignature(secret, canonicalRequest(req, body))
+	req.Header.Set("authorization", "HMAC "+keyID+":"+base64.StdEncoding.EncodeToString(signature))
+	return nil
+}
+
+// HMACAuthentication authenticates requests signed by SignRequest with a
+// secret shared with the client, looked up by key ID with secretLookup. The
+// signature covers the method, request URI, Date header and body, and the
+// Date must be within ClockSkew of the current time. The key ID is placed in
+// the request context.
+//
+// The body is read into memory to be verified, so limit its size before
+// this runs. A captured request can be replayed within the window; pair this
+// with a nonce check for requests that must not be repeated.
+func HMACAuthentication(secretLookup func(keyID string) ([]byte, bool), contextKey interface{}) AuthenticationFunc {
+	return func(w http.ResponseWriter, req *http.Request) (*http.Request, bool, error) {
+		credentials, ok := authorizationCredentials(req.Header.Get("authorization"), "HMAC")
+		if !ok {
+			return req, false, nil
+		}
+
+		i := strings.LastIndexByte(credentials, ':')
+		if i < 0 {
+			return req, false, nil
+		}
+
+		keyID := credentials[:i]
+		signature, err := base64.StdEncoding.DecodeString(credentials[i+1:])
+		if err != nil {
+			return req, false, nil
+		}
+
+		date, err := http.ParseTime(req.Header.Get("date"))
+		if err != nil {
+			return req, false, nil
+		}
+
+		if skew := time.Since(date); skew > ClockSkew || skew < -ClockSkew {
+			return req, false, nil
+		}
+
+		secret, ok := secretLookup(keyID)
+		if !ok {
+			return req, false, nil
+		}
+
+		body, err := readBody(req)
+		if err != nil {
+			return req, false, err
+		}
+
+		if !hmac.Equal(signature, requestSignature(secret, canonicalRequest(req, body))) {
+			return req, false, nil
+		}
+
+		if contextKey != nil {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, contextKey, keyID)
+			req = req.WithContext(ctx)
+		}
+
+		return req, true, nil
+	}
+}
+
+func HMACAuthenticationHandler(handler http.Handler, secretLookup func(keyID string) ([]byte, bool), contextKey interface{}) http.Handler {
+	return AuthenticationHandler(handler, HMACAuthentication(secretLookup, contextKey))
+}
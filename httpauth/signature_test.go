@@ -0,0 +1,96 @@
+package httpauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACAuthenticationHandler(t *testing.T) {
+	secrets := map[string][]byte{"client": []byte("secret")}
+	handler := HMACAuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if keyID, ok := req.Context().Value(testInfoKey{}).(string); !ok || keyID != "client" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// The body must still be readable after verification.
+		if body, err := io.ReadAll(req.Body); err != nil || string(body) != "payload" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), func(keyID string) ([]byte, bool) {
+		secret, ok := secrets[keyID]
+		return secret, ok
+	}, testInfoKey{})
+
+	signed := func(keyID string, secret []byte, date time.Time) *http.Request {
+		req := httptest.NewRequest("POST", "/resource?q=1", strings.NewReader("payload"))
+		req.Header.Set("date", date.UTC().Format(http.TimeFormat))
+		if err := SignRequest(req, keyID, secret); err != nil {
+			t.Fatal(err)
+		}
+
+		return req
+	}
+
+	for _, test := range []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{"valid", func() *http.Request {
+			return signed("client", secrets["client"], time.Now())
+		}, http.StatusOK},
+		{"within skew", func() *http.Request {
+			return signed("client", secrets["client"], time.Now().Add(-ClockSkew/2))
+		}, http.StatusOK},
+		{"unsigned", func() *http.Request {
+			return httptest.NewRequest("POST", "/resource?q=1", strings.NewReader("payload"))
+		}, http.StatusUnauthorized},
+		{"tampered body", func() *http.Request {
+			req := signed("client", secrets["client"], time.Now())
+			req.Body = io.NopCloser(strings.NewReader("payloaf"))
+			return req
+		}, http.StatusUnauthorized},
+		{"tampered query", func() *http.Request {
+			req := signed("client", secrets["client"], time.Now())
+			req.URL.RawQuery = "q=2"
+			return req
+		}, http.StatusUnauthorized},
+		{"tampered method", func() *http.Request {
+			req := signed("client", secrets["client"], time.Now())
+			req.Method = "PUT"
+			return req
+		}, http.StatusUnauthorized},
+		{"tampered date", func() *http.Request {
+			req := signed("client", secrets["client"], time.Now())
+			req.Header.Set("date", time.Now().Add(ClockSkew/2).UTC().Format(http.TimeFormat))
+			return req
+		}, http.StatusUnauthorized},
+		{"stale", func() *http.Request {
+			return signed("client", secrets["client"], time.Now().Add(-2*ClockSkew))
+		}, http.StatusUnauthorized},
+		{"future", func() *http.Request {
+			return signed("client", secrets["client"], time.Now().Add(2*ClockSkew))
+		}, http.StatusUnauthorized},
+		{"wrong secret", func() *http.Request {
+			return signed("client", []byte("other"), time.Now())
+		}, http.StatusUnauthorized},
+		{"unknown key", func() *http.Request {
+			return signed("other", secrets["client"], time.Now())
+		}, http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, test.req())
+
+		if w.Code != test.status {
+			t.Errorf("%s: request returned status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package id
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"sync"
+)
+
+// reader is the source of randomness for New, NewSortable and NewBatch.
+var reader io.Reader = rand.Reader
+
+// FallbackReader reads from a primary source of randomness, falling back to
+// an AES-CTR keystream if a read fails. The keystream is keyed from the
+// primary source when the FallbackReader is created.
+//
+// Security considerations: bytes produced by the fallback are only as
+// unpredictable as the key, which is never refreshed. Anyone who learns the
+// key, for example from a memory dump, can predict every byte the fallback
+// has produced or will produce. Processes must not share a FallbackReader
+// across a fork, or they will produce the same bytes. The fallback is
+// intended to ride out brief outages of the primary source; warn should be
+// used to alert on them.
+type FallbackReader struct {
+	primary io.Reader
+	warn    func(error)
+
+	mu     sync.Mutex
+	stream cipher.Stream
+}
+
+// NewFallbackReader returns a FallbackReader for primary, keying its fallback
+// from primary immediately. warn, if not nil, is called with the error each
+// time the fallback is used.
+func NewFallbackReader(primary io.Reader, warn func(error)) (*FallbackReader, error) {
+	seed := make([]byte, 32+aes.BlockSize)
+	if _, err := io.ReadFull(primary, seed); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(seed[:32])
+	if err != nil {
+		return nil, err
+	}
+
+	return &FallbackReader{
+		primary: primary,
+		warn:    warn,
+		stream:  cipher.NewCTR(block, seed[32:]),
+	}, nil
+}
+
+// Read fills p from the primary source, or from the fallback if that fails.
+// Any bytes read from the primary source before it failed are mixed into the
+// fallback's output. Read never returns an error.
+func (f *FallbackReader) Read(p []byte) (int, error) {
+	if _, err := io.ReadFull(f.primary, p); err != nil {
+		if f.warn != nil {
+			f.warn(err)
+		}
+
+		f.mu.Lock()
+		f.stream.XORKeyStream(p, p)
+		f.mu.Unlock()
+	}
+
+	return len(p), nil
+}
+
+// EnableFallback makes New, NewSortable and NewBatch fall back to a
+// FallbackReader keyed from crypto/rand if crypto/rand fails, calling warn
+// when they do. See FallbackReader for the security considerations. It must
+// be called before IDs are generated concurrently, typically at startup.
+func EnableFallback(warn func(error)) error {
+	fallbackReader, err := NewFallbackReader(rand.Reader, warn)
+	if err != nil {
+		return err
+	}
+
+	reader = fallbackReader
+	return nil
+}
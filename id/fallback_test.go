@@ -0,0 +1,62 @@
+package id
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFallbackReader(t *testing.T) {
+	seed := make([]byte, 48)
+	if _, err := rand.Read(seed); err != nil {
+		t.Fatal(err)
+	}
+
+	ones := bytes.Repeat([]byte{1}, 20)
+	unavailable := errors.New("unavailable")
+	primary := io.MultiReader(bytes.NewReader(seed), bytes.NewReader(ones), iotest.ErrReader(unavailable))
+
+	warnings := 0
+	fallbackReader, err := NewFallbackReader(primary, func(err error) {
+		if err != unavailable {
+			t.Errorf("incorrect warning %v", err)
+		}
+		warnings++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := NewFrom(fallbackReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(id[:], ones) || warnings != 0 {
+		t.Errorf("fallback used while primary was available: %v", id)
+	}
+
+	seen := map[ID]bool{}
+	for i := 0; i < 100; i++ {
+		id, err := NewFrom(fallbackReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id.IsZero() || seen[id] {
+			t.Fatalf("fallback returned a duplicate or zero ID %v", id)
+		}
+		seen[id] = true
+	}
+
+	if warnings != 100 {
+		t.Errorf("incorrect warning count %d, expected 100", warnings)
+	}
+
+	if _, err := NewFallbackReader(iotest.ErrReader(unavailable), nil); err != unavailable {
+		t.Errorf("expected error keying from an unavailable primary, got %v", err)
+	}
+}
@@ -1,7 +1,15 @@
 package id
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/bits"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestID(t *testing.T) {
@@ -24,6 +32,138 @@ func TestID(t *testing.T) {
 	}
 }
 
+func TestNewFrom(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, 30)
+
+	id, err := NewFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != (ID{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}) {
+		t.Errorf("incorrect ID value %v", id)
+	}
+
+	if _, err := NewFrom(bytes.NewReader(data[:10])); err == nil {
+		t.Error("expected error for short read")
+	}
+}
+
+func TestNewSortable(t *testing.T) {
+	before := time.Now()
+
+	id, err := NewSortable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// IDs created in the same nanosecond are ordered randomly.
+	time.Sleep(time.Millisecond)
+
+	nextID, err := NewSortable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created := id.Time(); created.Before(before) || created.After(time.Now()) {
+		t.Errorf("incorrect ID time %v", created)
+	}
+
+	if bytes.Compare(id[:], nextID[:]) >= 0 {
+		t.Errorf("consecutive sortable IDs out of order\n%v\n%v\n", id, nextID)
+	}
+}
+
+func TestNewBatch(t *testing.T) {
+	ids, err := NewBatch(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 10 {
+		t.Fatalf("expected 10 IDs, got %d", len(ids))
+	}
+
+	seen := map[ID]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("duplicate ID %v", id)
+		}
+		seen[id] = true
+	}
+
+	if ids, err := NewBatch(0); err != nil || len(ids) != 0 {
+		t.Errorf("empty batch: %v, %v", ids, err)
+	}
+
+	if _, err := NewBatch(-1); err != InvalidBatchSizeError {
+		t.Errorf("expected InvalidBatchSizeError for a negative batch, got %v", err)
+	}
+}
+
+func TestIDIsZero(t *testing.T) {
+	if !Zero.IsZero() || !(ID{}).IsZero() {
+		t.Error("zero ID not zero")
+	}
+
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id.IsZero() {
+		t.Error("random ID zero")
+	}
+
+	// Reassigning Zero must not change which ID is zero.
+	defer func(zero ID) {
+		Zero = zero
+	}(Zero)
+	Zero = id
+
+	if id.IsZero() || !(ID{}).IsZero() {
+		t.Error("IsZero depends on Zero")
+	}
+}
+
+func TestIDEqual(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !id.Equal(id) {
+		t.Error("equals error")
+	}
+
+	otherID := id
+	otherID[len(otherID)-1]++
+	if id.Equal(otherID) {
+		t.Error("equal different IDs")
+	}
+}
+
+func TestIDCompare(t *testing.T) {
+	id := ID{1}
+	if id.Compare(ID{0, 1}) != 1 || id.Compare(ID{1}) != 0 || id.Compare(ID{2}) != -1 {
+		t.Error("compare error")
+	}
+}
+
+func TestIDsSort(t *testing.T) {
+	ids, err := NewBatch(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Sort(IDs(ids))
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1].Compare(ids[i]) > 0 {
+			t.Errorf("IDs out of order at %d\n%v\n%v\n", i, ids[i-1], ids[i])
+		}
+	}
+}
+
 func TestIDBinaryMarshall(t *testing.T) {
 	id, err := New()
 	if err != nil {
@@ -75,6 +215,247 @@ func TestIDTextMarshall(t *testing.T) {
 	}
 }
 
+func TestIDJSONMarshall(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type payload struct {
+		ID ID `json:"id"`
+	}
+
+	data, err := json.Marshal(payload{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `{"id":"` + id.String() + `"}`; string(data) != expected {
+		t.Errorf("incorrect JSON value\n%s\n%s\n", data, expected)
+	}
+
+	unmarshalled := payload{}
+	if err := json.Unmarshal(data, &unmarshalled); err != nil {
+		t.Fatal(err)
+	}
+
+	if id != unmarshalled.ID {
+		t.Errorf("incorrect unmarshalled ID value\n%v\n%v\n", id, unmarshalled.ID)
+	}
+
+	if err := json.Unmarshal([]byte(`{"id":"`+id.String()[2:]+`"}`), &unmarshalled); err == nil {
+		t.Error("unmarshalled a short ID")
+	}
+}
+
+func TestParse(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedID, err := Parse(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != parsedID {
+		t.Errorf("incorrect parsed ID value\n%v\n%v\n", id, parsedID)
+	}
+
+	if _, err := Parse(id.String()[2:]); err != InvalidIDError {
+		t.Errorf("expected InvalidIDError, got %v", err)
+	}
+
+	_, err = Parse("zz" + id.String()[2:])
+	var invalidByteError hex.InvalidByteError
+	if !errors.As(err, &invalidByteError) {
+		t.Errorf("expected wrapped hex.InvalidByteError, got %v", err)
+	}
+
+	if MustParse(id.String()) != id {
+		t.Error("incorrect MustParse ID value")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustParse did not panic on an invalid ID")
+		}
+	}()
+	MustParse("invalid")
+}
+
+func TestUUID(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uuid := id.UUIDString()
+	if len(uuid) != 36 || uuid[8] != '-' || uuid[13] != '-' || uuid[18] != '-' || uuid[23] != '-' {
+		t.Errorf("incorrect UUID format %s", uuid)
+	}
+
+	uuidID, err := ParseUUID(uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if [16]byte(id[:16]) != [16]byte(uuidID[:16]) {
+		t.Errorf("incorrect parsed UUID value\n%v\n%v\n", id, uuidID)
+	}
+
+	if [4]byte(uuidID[16:]) != [4]byte{} {
+		t.Errorf("expected zero padding, got %v", uuidID[16:])
+	}
+
+	if _, err := ParseUUID(id.String()); err != InvalidIDError {
+		t.Errorf("expected InvalidIDError, got %v", err)
+	}
+}
+
+func TestBase32(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := id.Base32()
+	if len(encoded) != 32 {
+		t.Errorf("incorrect base32 length %d", len(encoded))
+	}
+
+	for _, s := range []string{encoded, strings.ToLower(encoded), encoded[:16] + "-" + encoded[16:]} {
+		base32ID, err := ParseBase32(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id != base32ID {
+			t.Errorf("incorrect parsed base32 ID value\n%v\n%v\n", id, base32ID)
+		}
+	}
+
+	zeroID, err := ParseBase32(strings.Repeat("O", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if zeroID != (ID{}) {
+		t.Errorf("incorrect parsed base32 ID value %v", zeroID)
+	}
+
+	if _, err := ParseBase32(encoded[:24]); err != InvalidIDError {
+		t.Errorf("expected InvalidIDError, got %v", err)
+	}
+
+	if _, err := ParseBase32("U" + encoded[1:]); !errors.Is(err, InvalidIDError) {
+		t.Errorf("expected InvalidIDError, got %v", err)
+	}
+}
+
+func TestIDTextUnmarshallInvalid(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	textID := ID{}
+	if err := textID.UnmarshalText(nil); err != EmptyIDError {
+		t.Errorf("expected EmptyIDError for empty input, got %v", err)
+	}
+
+	for _, text := range []string{
+		id.String()[2:],
+		id.String() + "00",
+		"zz" + id.String()[2:],
+	} {
+		err := textID.UnmarshalText([]byte(text))
+		if err == EmptyIDError || !errors.Is(err, InvalidIDError) {
+			t.Errorf("expected InvalidIDError for %q, got %v", text, err)
+		}
+	}
+}
+
+func TestObfuscate(t *testing.T) {
+	key := []byte("key")
+
+	id, err := NewSortable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obfuscated := id.Obfuscate(key)
+	if obfuscated == id.String() {
+		t.Error("obfuscated ID equals the ID")
+	}
+
+	deobfuscatedID, err := Deobfuscate(key, obfuscated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != deobfuscatedID {
+		t.Errorf("incorrect deobfuscated ID value\n%v\n%v\n", id, deobfuscatedID)
+	}
+
+	if wrongKeyID, err := Deobfuscate([]byte("other"), obfuscated); err != nil {
+		t.Fatal(err)
+	} else if wrongKeyID == id {
+		t.Error("deobfuscated ID with the wrong key")
+	}
+
+	// Sequential IDs should differ in about half of their obfuscated bits.
+	nextID := id
+	nextID[len(nextID)-1]++
+
+	obfuscatedID, err := Parse(obfuscated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obfuscatedNextID, err := Parse(nextID.Obfuscate(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	differing := 0
+	for i := range obfuscatedID {
+		differing += bits.OnesCount8(obfuscatedID[i] ^ obfuscatedNextID[i])
+	}
+
+	if differing < 40 || differing > 120 {
+		t.Errorf("obfuscated sequential IDs differ in %d of 160 bits", differing)
+	}
+}
+
+func TestIDAppend(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := []byte("id:")
+
+	text, err := id.AppendText(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(text) != "id:"+id.String() {
+		t.Errorf("incorrect appended text %s", text)
+	}
+
+	binary, err := id.AppendBinary(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(binary, append([]byte("id:"), id[:]...)) {
+		t.Errorf("incorrect appended binary %v", binary)
+	}
+}
+
 func TestIDString(t *testing.T) {
 	id, err := New()
 	if err != nil {
@@ -91,6 +472,69 @@ func TestIDString(t *testing.T) {
 	}
 }
 
+func TestIDValueScan(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := id.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := value.([]byte); !ok {
+		t.Fatalf("incorrect ID value type %T", value)
+	}
+
+	scannedID := ID{}
+	if err := scannedID.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+
+	if id != scannedID {
+		t.Errorf("incorrect scanned ID value\n%v\n%v\n", id, scannedID)
+	}
+}
+
+func TestIDScan(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bytesID := ID{}
+	if err := bytesID.Scan(id[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	if id != bytesID {
+		t.Errorf("incorrect scanned ID value\n%v\n%v\n", id, bytesID)
+	}
+
+	stringID := ID{}
+	if err := stringID.Scan(id.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if id != stringID {
+		t.Errorf("incorrect scanned ID value\n%v\n%v\n", id, stringID)
+	}
+
+	nilID := id
+	if err := nilID.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if nilID != (ID{}) {
+		t.Errorf("incorrect scanned ID value\n%v\n%v\n", ID{}, nilID)
+	}
+
+	if err := nilID.Scan(1); err != InvalidIDError {
+		t.Errorf("expected InvalidIDError, got %v", err)
+	}
+}
+
 func BenchmarkString(b *testing.B) {
 	id, err := New()
 	if err != nil {
@@ -114,3 +558,77 @@ func BenchmarkValue(b *testing.B) {
 		id.Value()
 	}
 }
+
+func BenchmarkNew(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := New(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewBatch(b *testing.B) {
+	const batchSize = 100
+	for i := 0; i < b.N; i += batchSize {
+		if _, err := NewBatch(batchSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEqual(b *testing.B) {
+	id, err := New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	otherID := id
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id.Equal(otherID)
+	}
+}
+
+func BenchmarkMarshalText(b *testing.B) {
+	id, err := New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id.MarshalText()
+	}
+}
+
+func BenchmarkAppendText(b *testing.B) {
+	id, err := New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	buf := make([]byte, 0, 40)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = id.AppendText(buf[:0])
+	}
+}
+
+func BenchmarkAppendBinary(b *testing.B) {
+	id, err := New()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	buf := make([]byte, 0, 20)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = id.AppendBinary(buf[:0])
+	}
+}
@@ -0,0 +1,298 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Backup records are written as a sequence of length-prefixed fields:
+//
+//	session ID, encoded session, group ID (empty if none)
+//
+// followed by the remaining TTL in milliseconds (0 if none) and the score of
+// the session within its group, each as a big-endian 8-byte value, then the
+// network the session is bound to (empty if none) as a length-prefixed field
+// and its metadata as a big-endian 4-byte count of fields followed by each
+// field name and value as length-prefixed fields.
+
+// maxBackupFieldLength bounds the length of a field read from a backup, so
+// that a corrupt length prefix cannot cause a huge allocation.
+const maxBackupFieldLength = 16 << 20
+
+var (
+	InvalidBackupError = errors.New("invalid backup")
+)
+
+func writeBackupField(w io.Writer, field []byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(field))); err != nil {
+		return err
+	}
+
+	_, err := w.Write(field)
+	return err
+}
+
+func readBackupField(rd io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(rd, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+
+	if length > maxBackupFieldLength {
+		return nil, InvalidBackupError
+	}
+
+	field := make([]byte, length)
+	if _, err := io.ReadFull(rd, field); err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+// Backup streams every session in the store to w, returning the number of
+// sessions written.
+func (r *SessionStore) Backup(w io.Writer) (int, error) {
+	defer r.observe("Backup")()
+
+	conn := r.replicaPool.Get()
+	defer conn.Close()
+
+	count := 0
+	err := r.scanSessions(conn, func(sessionIdStr string) error {
+		written, err := r.backupSession(conn, w, sessionIdStr)
+		if err != nil {
+			return err
+		}
+
+		if written {
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+func (r *SessionStore) backupSession(conn redis.Conn, w io.Writer, sessionIdStr string) (bool, error) {
+	conn.Send("GET", r.sessionKey(sessionIdStr))
+	conn.Send("PTTL", r.sessionKey(sessionIdStr))
+	conn.Send("GET", r.sessionToGroupKey(sessionIdStr))
+	conn.Send("GET", r.sessionNetworkKey(sessionIdStr))
+	conn.Send("HGETALL", r.sessionMetaKey(sessionIdStr))
+	if err := conn.Flush(); err != nil {
+		return false, err
+	}
+
+	reply, err := conn.Receive()
+	if err != nil {
+		return false, err
+	}
+
+	ttl, err := redis.Int64(conn.Receive())
+	if err != nil {
+		return false, err
+	}
+
+	gKey, err := redis.String(conn.Receive())
+	if err != nil && err != redis.ErrNil {
+		return false, err
+	}
+
+	network, err := redis.String(conn.Receive())
+	if err != nil && err != redis.ErrNil {
+		return false, err
+	}
+
+	meta, err := redis.StringMap(conn.Receive())
+	if err != nil {
+		return false, err
+	}
+
+	// The session expired between the scan and the read.
+	if reply == nil || ttl == -2 {
+		return false, nil
+	}
+
+	encodedSession, err := redis.Bytes(reply, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	var score float64
+	if gKey != "" {
+		score, err = redis.Float64(conn.Do("ZSCORE", gKey, sessionIdStr))
+		if err != nil && err != redis.ErrNil {
+			return false, err
+		}
+	}
+
+	if err := writeBackupField(w, []byte(sessionIdStr)); err != nil {
+		return false, err
+	}
+	if err := writeBackupField(w, encodedSession); err != nil {
+		return false, err
+	}
+	if err := writeBackupField(w, []byte(strings.TrimPrefix(gKey, r.groupKey("")))); err != nil {
+		return false, err
+	}
+	if err := binary.Write(w, binary.BigEndian, ttl); err != nil {
+		return false, err
+	}
+	if err := binary.Write(w, binary.BigEndian, math.Float64bits(score)); err != nil {
+		return false, err
+	}
+	if err := writeBackupField(w, []byte(network)); err != nil {
+		return false, err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(meta))); err != nil {
+		return false, err
+	}
+	for field, value := range meta {
+		if err := writeBackupField(w, []byte(field)); err != nil {
+			return false, err
+		}
+		if err := writeBackupField(w, []byte(value)); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Restore reads sessions written by Backup from rd into the store, honoring
+// their remaining TTLs. It returns the number of sessions restored.
+func (r *SessionStore) Restore(rd io.Reader) (int, error) {
+	defer r.observe("Restore")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	count := 0
+	for {
+		sessionIdBytes, err := readBackupField(rd)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		encodedSession, err := readBackupField(rd)
+		if err != nil {
+			return count, InvalidBackupError
+		}
+
+		groupIdBytes, err := readBackupField(rd)
+		if err != nil {
+			return count, InvalidBackupError
+		}
+
+		var ttl int64
+		if err := binary.Read(rd, binary.BigEndian, &ttl); err != nil {
+			return count, InvalidBackupError
+		}
+
+		var scoreBits uint64
+		if err := binary.Read(rd, binary.BigEndian, &scoreBits); err != nil {
+			return count, InvalidBackupError
+		}
+
+		network, err := readBackupField(rd)
+		if err != nil {
+			return count, InvalidBackupError
+		}
+
+		var metaCount uint32
+		if err := binary.Read(rd, binary.BigEndian, &metaCount); err != nil {
+			return count, InvalidBackupError
+		}
+
+		var meta []interface{}
+		for i := uint32(0); i < metaCount; i++ {
+			field, err := readBackupField(rd)
+			if err != nil {
+				return count, InvalidBackupError
+			}
+
+			value, err := readBackupField(rd)
+			if err != nil {
+				return count, InvalidBackupError
+			}
+
+			meta = append(meta, field, value)
+		}
+
+		if err := r.restoreSession(conn, string(sessionIdBytes), encodedSession, string(groupIdBytes), ttl, math.Float64frombits(scoreBits), string(network), meta); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+}
+
+// restoreSession writes a backed-up session. meta holds alternating field
+// names and values.
+func (r *SessionStore) restoreSession(conn redis.Conn, sessionIdStr string, encodedSession []byte, groupIdStr string, ttl int64, score float64, network string, meta []interface{}) error {
+	expiry := []interface{}{}
+	if ttl > 0 {
+		expiry = append(expiry, "PX", ttl)
+	}
+
+	conn.Send("MULTI")
+	conn.Send("SET", append([]interface{}{r.sessionKey(sessionIdStr), encodedSession}, expiry...)...)
+
+	// A binding or metadata left over from a previous session with this ID
+	// must not survive the restore.
+	if network != "" {
+		conn.Send("SET", append([]interface{}{r.sessionNetworkKey(sessionIdStr), network}, expiry...)...)
+	} else {
+		conn.Send("DEL", r.sessionNetworkKey(sessionIdStr))
+	}
+
+	conn.Send("DEL", r.sessionMetaKey(sessionIdStr))
+	if len(meta) > 0 {
+		conn.Send("HMSET", append([]interface{}{r.sessionMetaKey(sessionIdStr)}, meta...)...)
+		if ttl > 0 {
+			conn.Send("PEXPIRE", r.sessionMetaKey(sessionIdStr), ttl)
+		}
+	}
+
+	if groupIdStr != "" {
+		gKey := r.groupKey(groupIdStr)
+		conn.Send("SET", append([]interface{}{r.sessionToGroupKey(sessionIdStr), gKey}, expiry...)...)
+		addToCappedSortedSetScript.Send(conn, r.cappedSortedSetArgs(groupIdStr, r.maxSessions, "", score, sessionIdStr)...)
+	}
+
+	res, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return err
+	}
+	for _, elem := range res {
+		if err, ok := elem.(error); ok {
+			return err
+		}
+	}
+
+	if groupIdStr != "" {
+		// The capped sorted set script is the last command in the transaction.
+		evicted, err := redis.Strings(res[len(res)-1], nil)
+		if err != nil {
+			return err
+		}
+
+		r.reportEvicted(evicted, groupIdStr)
+	}
+
+	return nil
+}
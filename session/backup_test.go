@@ -0,0 +1,210 @@
+package session
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestBackupRestore(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionIDs := make([]id.ID, 3)
+	for i := range sessionIDs {
+		sessionIDs[i], err = id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sessionStore.SetSession(sessionIDs[i], userID, sessionIDs[i].String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ungroupedSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(ungroupedSessionID, nil, "ungrouped"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The network binding and metadata must survive a restore, or a
+	// restored session could be used from any address.
+	_, allowed, err := net.ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boundSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSessionBound(boundSessionID, nil, "bound", allowed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSessionMeta(boundSessionID, map[string]string{"device": "phone", "seen": "now"}); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := bytes.NewBuffer([]byte{})
+	count, err := sessionStore.Backup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 5 {
+		t.Errorf("Expected 5 sessions backed up, got %d", count)
+	}
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err = sessionStore.Restore(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 5 {
+		t.Errorf("Expected 5 sessions restored, got %d", count)
+	}
+
+	for _, sessionID := range sessionIDs {
+		var session string
+		if err := sessionStore.Session(sessionID, &session); err != nil {
+			t.Fatal(err)
+		}
+
+		if session != sessionID.String() {
+			t.Errorf("incorrect session, %s, expected %s", session, sessionID.String())
+		}
+	}
+
+	var session string
+	if err := sessionStore.Session(ungroupedSessionID, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	if session != "ungrouped" {
+		t.Errorf("incorrect session, %s, expected %s", session, "ungrouped")
+	}
+
+	ttl, err := redis.Int64(conn.Do("PTTL", "s"+ungroupedSessionID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl <= 0 || ttl > int64(time.Minute/time.Millisecond) {
+		t.Errorf("incorrect restored TTL, %d", ttl)
+	}
+
+	for _, test := range []struct {
+		ip      string
+		allowed bool
+	}{
+		{"192.0.2.1", true},
+		{"198.51.100.1", false},
+	} {
+		allowed, err := sessionStore.CheckSessionIP(boundSessionID, net.ParseIP(test.ip))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if allowed != test.allowed {
+			t.Errorf("restored session from %s: allowed %t, expected %t", test.ip, allowed, test.allowed)
+		}
+	}
+
+	_, meta, metaTTL, err := sessionStore.SessionContext(boundSessionID, &session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(meta) != 2 || meta["device"] != "phone" || meta["seen"] != "now" {
+		t.Errorf("incorrect restored metadata %v", meta)
+	}
+
+	if metaTTL <= 0 {
+		t.Errorf("incorrect restored TTL, %s", metaTTL)
+	}
+
+	for _, key := range []string{"n" + boundSessionID.String(), "m" + boundSessionID.String()} {
+		ttl, err := redis.Int64(conn.Do("PTTL", key))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ttl <= 0 || ttl > int64(time.Minute/time.Millisecond) {
+			t.Errorf("incorrect restored TTL of %s, %d", key, ttl)
+		}
+	}
+
+	// TODO: get the group key some other way
+	res, err := redis.Strings(conn.Do("ZRANGE", "g"+userID.String(), 0, -1))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(res) != 3 {
+		t.Errorf("Expected 3 sessions in group, got %d: %v", len(res), res)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestoreInvalidBackup(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		backup []byte
+	}{
+		{"oversized field", []byte{0xff, 0xff, 0xff, 0xff}},
+		{"truncated field", []byte{0, 0, 0, 4, 'a'}},
+		{"truncated record", []byte{0, 0, 0, 1, 'a'}},
+	} {
+		count, err := sessionStore.Restore(bytes.NewReader(test.backup))
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		} else if test.name == "oversized field" && err != InvalidBackupError {
+			t.Errorf("%s: error %v, expected InvalidBackupError", test.name, err)
+		}
+
+		if count != 0 {
+			t.Errorf("%s: restored %d sessions", test.name, count)
+		}
+	}
+}
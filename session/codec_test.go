@@ -0,0 +1,80 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+type testCodecSession struct {
+	UserID string `json:"user_id"`
+	Admin  bool   `json:"admin"`
+}
+
+func TestJSONCodec(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		Codec:           JSONCodec{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, nil, testCodecSession{UserID: "1", Admin: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := redis.String(conn.Do("GET", "s"+sessionID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if encoded != `{"user_id":"1","admin":true}` {
+		t.Errorf("incorrect encoded session %s", encoded)
+	}
+
+	var session testCodecSession
+	if err := sessionStore.Session(sessionID, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	if session != (testCodecSession{UserID: "1", Admin: true}) {
+		t.Errorf("incorrect session %+v", session)
+	}
+
+	if err := sessionStore.DeleteSession(sessionID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGobCodec(t *testing.T) {
+	codec := GobCodec{}
+	encoded, err := codec.Marshal(testCodecSession{UserID: "1", Admin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var session testCodecSession
+	if err := codec.Unmarshal(encoded, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	if session != (testCodecSession{UserID: "1", Admin: true}) {
+		t.Errorf("incorrect session %+v", session)
+	}
+}
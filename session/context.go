@@ -0,0 +1,62 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// contextConn bounds the replies to commands on a connection by the deadline
+// of its context, and fails commands once the context is done. A command
+// already waiting on a reply is not interrupted by cancellation alone.
+type contextConn struct {
+	redis.Conn
+	ctx context.Context
+}
+
+func (c contextConn) timeout() (time.Duration, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		return 0, nil
+	}
+
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return 0, context.DeadlineExceeded
+	}
+
+	return timeout, nil
+}
+
+func (c contextConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	timeout, err := c.timeout()
+	if err != nil {
+		return nil, err
+	}
+
+	return redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c contextConn) Receive() (interface{}, error) {
+	timeout, err := c.timeout()
+	if err != nil {
+		return nil, err
+	}
+
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+// getContext gets a connection from pool whose commands are bound by ctx.
+func getContext(ctx context.Context, pool *redis.Pool) (redis.Conn, error) {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return contextConn{Conn: conn, ctx: ctx}, nil
+}
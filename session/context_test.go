@@ -0,0 +1,83 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestSessionWithContext(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := sessionStore.SetSessionWithContext(ctx, sessionID, userID, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var returnedUserID string
+	if err := sessionStore.SessionWithContext(ctx, sessionID, &returnedUserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if returnedUserID != "1" {
+		t.Errorf("incorrect user ID, %s, expected %s", returnedUserID, "1")
+	}
+
+	cancel()
+
+	if err := sessionStore.SetSessionWithContext(ctx, sessionID, userID, "2"); err != context.Canceled {
+		t.Errorf("expected context.Canceled from SetSessionWithContext, got %v", err)
+	}
+
+	if err := sessionStore.SessionWithContext(ctx, sessionID, &returnedUserID); err != context.Canceled {
+		t.Errorf("expected context.Canceled from SessionWithContext, got %v", err)
+	}
+
+	if err := sessionStore.DeleteSessionWithContext(ctx, sessionID); err != context.Canceled {
+		t.Errorf("expected context.Canceled from DeleteSessionWithContext, got %v", err)
+	}
+
+	if err := sessionStore.InvalidateSessionsWithContext(ctx, userID); err != context.Canceled {
+		t.Errorf("expected context.Canceled from InvalidateSessionsWithContext, got %v", err)
+	}
+
+	// Nothing was changed with the cancelled context.
+	if err := sessionStore.Session(sessionID, &returnedUserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if returnedUserID != "1" {
+		t.Errorf("incorrect user ID, %s, expected %s", returnedUserID, "1")
+	}
+
+	if err := sessionStore.DeleteSessionWithContext(context.Background(), sessionID); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"crypto/ed25519"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+const challengeDuration = time.Minute
+
+// Keys: challenge key
+// Arguments: device ID
+const consumeChallenge = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+
+return 0
+`
+
+var (
+	consumeChallengeScript = redis.NewScript(1, consumeChallenge)
+)
+
+func (r *SessionStore) challengeKey(nonce string) string {
+	return r.keyPrefix + "c" + nonce
+}
+
+// IssueChallenge returns a one-time nonce for deviceID to sign with its
+// Ed25519 key. The nonce expires if it is not verified within a minute.
+func (r *SessionStore) IssueChallenge(deviceID string) (id.ID, error) {
+	defer r.observe("IssueChallenge")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	nonce, err := id.New()
+	if err != nil {
+		return nonce, err
+	}
+
+	if _, err := conn.Do("SETEX", r.challengeKey(nonce.String()), int64(challengeDuration/time.Second), deviceID); err != nil {
+		return nonce, err
+	}
+
+	return nonce, nil
+}
+
+// VerifyChallenge checks that signature is a valid signature of nonce by
+// pubkey and that nonce was issued to deviceID and not yet used. On success
+// the nonce is consumed and the ID of a new session for deviceID, grouped by
+// deviceID, is returned. The session ID is freshly generated rather than the
+// nonce, which was sent to the device in the clear.
+func (r *SessionStore) VerifyChallenge(deviceID string, nonce id.ID, signature []byte, pubkey ed25519.PublicKey) (id.ID, bool, error) {
+	var sessionID id.ID
+	if len(pubkey) != ed25519.PublicKeySize || !ed25519.Verify(pubkey, nonce[:], signature) {
+		return sessionID, false, nil
+	}
+
+	if err := r.consumeChallenge(deviceID, nonce); err != nil {
+		if err == NoSessionFoundError {
+			return sessionID, false, nil
+		}
+
+		return sessionID, false, err
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		return sessionID, false, err
+	}
+
+	if err := r.SetSession(sessionID, deviceID, deviceID); err != nil {
+		return sessionID, false, err
+	}
+
+	return sessionID, true, nil
+}
+
+func (r *SessionStore) consumeChallenge(deviceID string, nonce id.ID) error {
+	defer r.observe("VerifyChallenge")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	consumed, err := redis.Int(consumeChallengeScript.Do(conn, r.challengeKey(nonce.String()), deviceID))
+	if err != nil {
+		return err
+	}
+
+	if consumed == 0 {
+		return NoSessionFoundError
+	}
+
+	return nil
+}
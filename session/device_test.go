@@ -0,0 +1,83 @@
+package session
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func TestDeviceChallenge(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	const deviceID = "device"
+
+	pubkey, privkey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPubkey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce, err := sessionStore.IssueChallenge(deviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := ed25519.Sign(privkey, nonce[:])
+
+	if _, ok, err := sessionStore.VerifyChallenge(deviceID, nonce, signature, otherPubkey); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("verified challenge with a bad signature")
+	}
+
+	sessionID, ok, err := sessionStore.VerifyChallenge(deviceID, nonce, signature, pubkey)
+	if err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Error("failed to verify valid challenge")
+	}
+
+	if sessionID == nonce {
+		t.Error("session ID is the challenge nonce")
+	}
+
+	var session string
+	if err := sessionStore.Session(nonce, &session); err != NoSessionFoundError {
+		t.Errorf("expected no session for the nonce, got %v", err)
+	}
+
+	if err := sessionStore.Session(sessionID, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	if session != deviceID {
+		t.Errorf("incorrect session, %s, expected %s", session, deviceID)
+	}
+
+	if _, ok, err := sessionStore.VerifyChallenge(deviceID, nonce, signature, pubkey); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("verified replayed challenge")
+	}
+
+	if err := sessionStore.InvalidateSessions(deviceID); err != nil {
+		t.Error(err)
+	}
+}
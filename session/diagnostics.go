@@ -0,0 +1,100 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Diagnostics is the report served by DiagnosticsHandler.
+type Diagnostics struct {
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+
+	// ActiveConns and IdleConns count the primary pool's connections.
+	ActiveConns int `json:"active_conns"`
+	IdleConns   int `json:"idle_conns"`
+
+	// ScriptsLoaded counts the store's scripts cached by the server, out of
+	// ScriptsTotal.
+	ScriptsLoaded int `json:"scripts_loaded"`
+	ScriptsTotal  int `json:"scripts_total"`
+
+	// ClockSkew is the Redis server time minus the local time, in
+	// milliseconds, measured at the midpoint of the TIME round trip.
+	ClockSkew int64 `json:"clock_skew_ms"`
+}
+
+// DiagnosticsHandler returns a handler that reports the health of the
+// primary Redis server as JSON, with a 503 status if it is unreachable. The
+// handler performs no authentication of its own; wrap it before exposing it.
+func (r *SessionStore) DiagnosticsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		diagnostics := r.diagnostics(req)
+
+		w.Header().Set("content-type", "application/json")
+		if !diagnostics.Reachable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		json.NewEncoder(w).Encode(diagnostics)
+	})
+}
+
+func (r *SessionStore) diagnostics(req *http.Request) Diagnostics {
+	defer r.observe("Diagnostics")()
+
+	diagnostics := Diagnostics{
+		ScriptsTotal: len(scripts),
+	}
+
+	conn, err := getContext(req.Context(), r.pool)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return diagnostics
+	}
+	defer conn.Close()
+
+	// The connection in use is counted as active.
+	diagnostics.ActiveConns = r.pool.ActiveCount()
+	diagnostics.IdleConns = r.pool.IdleCount()
+
+	if _, err := conn.Do("PING"); err != nil {
+		diagnostics.Error = err.Error()
+		return diagnostics
+	}
+	diagnostics.Reachable = true
+
+	hashes := make([]interface{}, len(scripts))
+	for i, script := range scripts {
+		hashes[i] = script.Hash()
+	}
+
+	exists, err := redis.Ints(conn.Do("SCRIPT", append([]interface{}{"EXISTS"}, hashes...)...))
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return diagnostics
+	}
+
+	for _, loaded := range exists {
+		diagnostics.ScriptsLoaded += loaded
+	}
+
+	before := time.Now()
+	serverTime, err := redis.Int64s(conn.Do("TIME"))
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return diagnostics
+	}
+	after := time.Now()
+
+	if len(serverTime) == 2 {
+		local := before.Add(after.Sub(before) / 2)
+		server := time.Unix(serverTime[0], serverTime[1]*int64(time.Microsecond))
+		diagnostics.ClockSkew = int64(server.Sub(local) / time.Millisecond)
+	}
+
+	return diagnostics
+}
@@ -0,0 +1,61 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDiagnosticsHandler(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		LoadScripts:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	sessionStore.DiagnosticsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("incorrect status, %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	var report map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"reachable", "active_conns", "idle_conns", "scripts_loaded", "scripts_total", "clock_skew_ms"} {
+		if _, ok := report[field]; !ok {
+			t.Errorf("missing field %s in %v", field, report)
+		}
+	}
+
+	if report["reachable"] != true {
+		t.Errorf("expected Redis to be reachable, got %v", report)
+	}
+
+	if report["scripts_loaded"] != report["scripts_total"] {
+		t.Errorf("expected all scripts to be loaded, got %v", report)
+	}
+
+	unreachableStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            "127.0.0.1:1",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	unreachableStore.DiagnosticsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("incorrect status for unreachable Redis, %d, expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
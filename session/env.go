@@ -0,0 +1,82 @@
+package session
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// OptionsFromEnv reads SessionStoreOptions from environment variables with
+// the given prefix. Unset variables leave the corresponding option at its
+// zero value. The variables are:
+//
+//	<prefix>ADDR              Redis address, e.g. "localhost:6379"
+//	<prefix>PASSWORD          Redis password
+//	<prefix>DB                Redis database number, e.g. "2"
+//	<prefix>TLS               whether to connect with TLS, e.g. "true"
+//	<prefix>SESSION_DURATION  session duration, e.g. "24h"
+//	<prefix>MAX_SESSIONS      maximum sessions per group
+//
+// When TLS is true, TLSConfig is an empty tls.Config, so certificates are
+// verified against the host of the address with the system roots. TLS
+// accepts the values strconv.ParseBool does.
+func OptionsFromEnv(prefix string) (SessionStoreOptions, error) {
+	options := SessionStoreOptions{
+		Addr:     os.Getenv(prefix + "ADDR"),
+		Password: os.Getenv(prefix + "PASSWORD"),
+	}
+
+	if value := os.Getenv(prefix + "DB"); value != "" {
+		db, err := strconv.Atoi(value)
+		if err != nil {
+			return options, fmt.Errorf("%sDB: %v", prefix, err)
+		}
+
+		if db < 0 {
+			return options, fmt.Errorf("%sDB: must not be negative", prefix)
+		}
+
+		options.DB = db
+	}
+
+	if value := os.Getenv(prefix + "TLS"); value != "" {
+		useTLS, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("%sTLS: %v", prefix, err)
+		}
+
+		if useTLS {
+			options.TLSConfig = &tls.Config{}
+		}
+	}
+
+	if value := os.Getenv(prefix + "SESSION_DURATION"); value != "" {
+		sessionDuration, err := time.ParseDuration(value)
+		if err != nil {
+			return options, fmt.Errorf("%sSESSION_DURATION: %v", prefix, err)
+		}
+
+		if sessionDuration < time.Second {
+			return options, fmt.Errorf("%sSESSION_DURATION: must be at least 1s", prefix)
+		}
+
+		options.SessionDuration = sessionDuration
+	}
+
+	if value := os.Getenv(prefix + "MAX_SESSIONS"); value != "" {
+		maxSessions, err := strconv.Atoi(value)
+		if err != nil {
+			return options, fmt.Errorf("%sMAX_SESSIONS: %v", prefix, err)
+		}
+
+		if maxSessions < 0 {
+			return options, fmt.Errorf("%sMAX_SESSIONS: must not be negative", prefix)
+		}
+
+		options.MaxSessions = maxSessions
+	}
+
+	return options, nil
+}
@@ -0,0 +1,94 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("TEST_ADDR", "localhost:6379")
+	t.Setenv("TEST_PASSWORD", "password")
+	t.Setenv("TEST_DB", "2")
+	t.Setenv("TEST_TLS", "true")
+	t.Setenv("TEST_SESSION_DURATION", "1h30m")
+	t.Setenv("TEST_MAX_SESSIONS", "5")
+
+	options, err := OptionsFromEnv("TEST_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options.Addr != "localhost:6379" {
+		t.Errorf("incorrect addr, %s", options.Addr)
+	}
+
+	if options.Password != "password" {
+		t.Errorf("incorrect password, %s", options.Password)
+	}
+
+	if options.DB != 2 {
+		t.Errorf("incorrect db, %d", options.DB)
+	}
+
+	if options.TLSConfig == nil {
+		t.Error("expected a TLS config")
+	}
+
+	if options.SessionDuration != 90*time.Minute {
+		t.Errorf("incorrect session duration, %s", options.SessionDuration)
+	}
+
+	if options.MaxSessions != 5 {
+		t.Errorf("incorrect max sessions, %d", options.MaxSessions)
+	}
+}
+
+func TestOptionsFromEnvTLS(t *testing.T) {
+	for _, value := range []string{"", "false", "0"} {
+		t.Setenv("TEST_TLS", value)
+
+		options, err := OptionsFromEnv("TEST_")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if options.TLSConfig != nil {
+			t.Errorf("%q: unexpected TLS config", value)
+		}
+	}
+}
+
+func TestOptionsFromEnvInvalid(t *testing.T) {
+	t.Setenv("TEST_SESSION_DURATION", "forever")
+	if _, err := OptionsFromEnv("TEST_"); err == nil {
+		t.Error("expected error for malformed session duration")
+	}
+
+	t.Setenv("TEST_SESSION_DURATION", "1h")
+	t.Setenv("TEST_MAX_SESSIONS", "five")
+	if _, err := OptionsFromEnv("TEST_"); err == nil {
+		t.Error("expected error for malformed max sessions")
+	}
+
+	t.Setenv("TEST_MAX_SESSIONS", "-1")
+	if _, err := OptionsFromEnv("TEST_"); err == nil {
+		t.Error("expected error for negative max sessions")
+	}
+
+	t.Setenv("TEST_MAX_SESSIONS", "5")
+	t.Setenv("TEST_DB", "two")
+	if _, err := OptionsFromEnv("TEST_"); err == nil {
+		t.Error("expected error for malformed db")
+	}
+
+	t.Setenv("TEST_DB", "-1")
+	if _, err := OptionsFromEnv("TEST_"); err == nil {
+		t.Error("expected error for negative db")
+	}
+
+	t.Setenv("TEST_DB", "2")
+	t.Setenv("TEST_TLS", "yes")
+	if _, err := OptionsFromEnv("TEST_"); err == nil {
+		t.Error("expected error for malformed tls")
+	}
+}
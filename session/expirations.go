@@ -0,0 +1,80 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+// expiredEventsChannel is the keyspace notification channel for expired keys
+// in a database.
+const expiredEventsChannel = "__keyevent@%d__:expired"
+
+// WatchExpirations returns a channel of the IDs of sessions as they expire,
+// which is closed when ctx is cancelled or the subscription fails. It relies
+// on Redis keyspace notifications, which must be enabled for expired events,
+// e.g. with "CONFIG SET notify-keyspace-events Ex". Redis delivers events at
+// most once, so an expiration may be missed if the subscriber disconnects.
+//
+// The subscription uses its own connection rather than one from the pool,
+// since a pooled connection cannot be closed while another goroutine is
+// receiving on it.
+func (r *SessionStore) WatchExpirations(ctx context.Context) (<-chan id.ID, error) {
+	conn, err := r.pool.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(fmt.Sprintf(expiredEventsChannel, r.db)); err != nil {
+		psc.Close()
+		return nil, err
+	}
+
+	expirations := make(chan id.ID)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+
+		// Closing the underlying connection makes Receive fail, ending the
+		// reader.
+		conn.Close()
+	}()
+
+	go func() {
+		defer close(expirations)
+		defer close(done)
+
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				key := string(v.Data)
+				if !strings.HasPrefix(key, r.sessionKey("")) {
+					continue
+				}
+
+				sessionID := id.ID{}
+				if err := sessionID.UnmarshalText([]byte(strings.TrimPrefix(key, r.sessionKey("")))); err != nil {
+					continue
+				}
+
+				select {
+				case expirations <- sessionID:
+				case <-ctx.Done():
+					return
+				}
+			case error:
+				return
+			}
+		}
+	}()
+
+	return expirations, nil
+}
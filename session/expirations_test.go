@@ -0,0 +1,81 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestWatchExpirations(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("CONFIG", "SET", "notify-keyspace-events", "Ex"); err != nil {
+		t.Skipf("keyspace notifications unavailable: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	expirations, err := sessionStore.WatchExpirations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, nil, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for expiredSessionID := range expirations {
+		if expiredSessionID == sessionID {
+			return
+		}
+	}
+
+	t.Errorf("expiration of session %s not received", sessionID)
+}
+
+func TestWatchExpirationsCancel(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	expirations, err := sessionStore.WatchExpirations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-expirations:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("expirations channel not closed after cancellation")
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package session
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// Percentiles summarizes the recorded latencies of an operation. Percentiles
+// are accurate to within a factor of two.
+type Percentiles struct {
+	Count         int64
+	P50, P95, P99 time.Duration
+}
+
+// latencyHistogram counts latencies in buckets by their bit length in
+// nanoseconds, so bucket i holds latencies below 2^i ns.
+type latencyHistogram struct {
+	counts [64]int64
+	count  int64
+	max    time.Duration
+}
+
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	rank := int64(math.Ceil(p * float64(h.count)))
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen < rank || count == 0 {
+			continue
+		}
+
+		upper := time.Duration(uint64(1)<<uint(i) - 1)
+		if upper > h.max {
+			return h.max
+		}
+		return upper
+	}
+
+	return h.max
+}
+
+type latencyStats struct {
+	mu         sync.Mutex
+	histograms map[string]*latencyHistogram
+}
+
+func newLatencyStats() *latencyStats {
+	return &latencyStats{
+		histograms: make(map[string]*latencyHistogram),
+	}
+}
+
+func (l *latencyStats) record(op string, d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.histograms[op]
+	if !ok {
+		h = &latencyHistogram{}
+		l.histograms[op] = h
+	}
+
+	h.counts[bits.Len64(uint64(d))]++
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+func (l *latencyStats) percentiles() map[string]Percentiles {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	percentiles := make(map[string]Percentiles, len(l.histograms))
+	for op, h := range l.histograms {
+		percentiles[op] = Percentiles{
+			Count: h.count,
+			P50:   h.percentile(0.5),
+			P95:   h.percentile(0.95),
+			P99:   h.percentile(0.99),
+		}
+	}
+
+	return percentiles
+}
+
+// LatencyStats returns latency percentiles for each operation performed by
+// the store, or nil unless RecordLatency is set.
+func (r *SessionStore) LatencyStats() map[string]Percentiles {
+	if r.latencies == nil {
+		return nil
+	}
+
+	return r.latencies.percentiles()
+}
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLatencyPercentiles(t *testing.T) {
+	latencies := newLatencyStats()
+	for i := 1; i <= 100; i++ {
+		latencies.record("op", time.Duration(i)*time.Millisecond)
+	}
+
+	percentiles, ok := latencies.percentiles()["op"]
+	if !ok {
+		t.Fatal("no percentiles recorded for op")
+	}
+
+	if percentiles.Count != 100 {
+		t.Errorf("incorrect count %d, expected 100", percentiles.Count)
+	}
+
+	for _, c := range []struct {
+		name          string
+		got, expected time.Duration
+	}{
+		{"p50", percentiles.P50, 50 * time.Millisecond},
+		{"p95", percentiles.P95, 95 * time.Millisecond},
+		{"p99", percentiles.P99, 99 * time.Millisecond},
+	} {
+		if c.got < c.expected || c.got >= 2*c.expected {
+			t.Errorf("incorrect %s %s, expected about %s", c.name, c.got, c.expected)
+		}
+	}
+
+	if percentiles.P99 > 100*time.Millisecond {
+		t.Errorf("p99 %s exceeds the maximum recorded latency", percentiles.P99)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		RecordLatency:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := sessionStore.Ping(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	percentiles, ok := sessionStore.LatencyStats()["Ping"]
+	if !ok {
+		t.Fatal("no percentiles recorded for Ping")
+	}
+
+	if percentiles.Count != 3 {
+		t.Errorf("incorrect count %d, expected 3", percentiles.Count)
+	}
+
+	if percentiles.P50 <= 0 || percentiles.P50 > percentiles.P99 {
+		t.Errorf("inconsistent percentiles %+v", percentiles)
+	}
+
+	sessionStore, err = NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := sessionStore.LatencyStats(); stats != nil {
+		t.Errorf("expected no latency stats when not recording, got %v", stats)
+	}
+}
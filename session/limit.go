@@ -0,0 +1,34 @@
+package session
+
+import (
+	"time"
+)
+
+func (r *SessionStore) groupLimitKey(groupId string) string {
+	return r.keyPrefix + "k" + groupId
+}
+
+// SetGroupLimit caps the number of sessions in a group at max, overriding
+// MaxSessions, for example to apply per-plan limits. A max of 0 leaves the
+// group uncapped. The override expires after ttl, or never if ttl is 0. It
+// is enforced as sessions are added, so a group already above max is only
+// trimmed when its next session is set.
+func (r *SessionStore) SetGroupLimit(groupId interface{}, max int, ttl time.Duration) error {
+	defer r.observe("SetGroupLimit")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	groupIdStr, err := interfaceToString(groupId)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{r.groupLimitKey(groupIdStr), max}
+	if ttl > 0 {
+		args = append(args, "PX", int64(ttl/time.Millisecond))
+	}
+
+	_, err = conn.Do("SET", args...)
+	return err
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestSetGroupLimit(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		MaxSessions:     5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		limit, expected int
+	}{
+		{2, 2},
+		{10, 10},
+		{-1, 5},
+	} {
+		userID, err := id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.limit >= 0 {
+			if err := sessionStore.SetGroupLimit(userID, test.limit, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i := 0; i < 12; i++ {
+			sessionID, err := id.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := sessionStore.SetSession(sessionID, userID, userID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// TODO: get the group key some other way
+		res, err := redis.Strings(conn.Do("ZRANGE", "g"+userID.String(), 0, -1))
+		if err != nil {
+			t.Error(err)
+		}
+		if len(res) != test.expected {
+			t.Errorf("Expected %d sessions in group with limit %d, got %d", test.expected, test.limit, len(res))
+		}
+
+		if err := sessionStore.InvalidateSessions(userID); err != nil {
+			t.Error(err)
+		}
+	}
+}
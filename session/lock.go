@@ -0,0 +1,63 @@
+package session
+
+import (
+	"time"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+// Keys: lock key
+// Arguments: token
+const unlock = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+
+return 0
+`
+
+var (
+	unlockScript = redis.NewScript(1, unlock)
+)
+
+func (r *SessionStore) lockKey(key string) string {
+	return r.keyPrefix + "l" + key
+}
+
+// Lock attempts to acquire a distributed lock on key that expires after ttl.
+// If the lock was acquired, the returned token must be passed to Unlock.
+func (r *SessionStore) Lock(key string, ttl time.Duration) (id.ID, bool, error) {
+	defer r.observe("Lock")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	token, err := id.New()
+	if err != nil {
+		return token, false, err
+	}
+
+	reply, err := conn.Do("SET", r.lockKey(key), token.String(), "NX", "PX", int64(ttl/time.Millisecond))
+	if err != nil {
+		return token, false, err
+	}
+
+	return token, reply != nil, nil
+}
+
+// Unlock releases a lock on key acquired by Lock. It returns false if the lock
+// is not held with the given token.
+func (r *SessionStore) Unlock(key string, token id.ID) (bool, error) {
+	defer r.observe("Unlock")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	deleted, err := redis.Int(unlockScript.Do(conn, r.lockKey(key), token.String()))
+	if err != nil {
+		return false, err
+	}
+
+	return deleted == 1, nil
+}
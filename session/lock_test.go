@@ -0,0 +1,63 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestLock(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	token, ok, err := sessionStore.Lock("payment", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatal("failed to acquire uncontended lock")
+	}
+
+	if _, ok, err := sessionStore.Lock("payment", time.Minute); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("acquired contended lock")
+	}
+
+	otherToken, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unlocked, err := sessionStore.Unlock("payment", otherToken); err != nil {
+		t.Fatal(err)
+	} else if unlocked {
+		t.Error("lock released by a non-owner")
+	}
+
+	if unlocked, err := sessionStore.Unlock("payment", token); err != nil {
+		t.Fatal(err)
+	} else if !unlocked {
+		t.Error("lock not released by the owner")
+	}
+
+	if _, ok, err := sessionStore.Lock("payment", time.Minute); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Error("failed to acquire released lock")
+	}
+}
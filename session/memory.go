@@ -0,0 +1,257 @@
+package session
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often a MemoryStore deletes expired entries.
+const memorySweepInterval = time.Second
+
+type memorySession struct {
+	encoded []byte
+	groupId string
+	expires time.Time
+}
+
+type memoryBucket struct {
+	last    int64
+	tokens  float64
+	expires time.Time
+}
+
+// MemoryStore is a Store kept in process memory, for tests and deployments
+// without Redis. Sessions are not shared between processes and are lost on
+// restart.
+type MemoryStore struct {
+	mu          sync.Mutex
+	sessions    map[string]memorySession
+	groups      map[string]map[string]float64
+	buckets     map[string]memoryBucket
+	duration    time.Duration
+	maxSessions int
+	codec       Codec
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewMemoryStore returns a MemoryStore using the SessionDuration,
+// GracePeriod, MaxSessions and Codec options; the rest are ignored. Expired
+// entries are deleted in the background until Close is called.
+func NewMemoryStore(options SessionStoreOptions) *MemoryStore {
+	m := &MemoryStore{
+		sessions:    make(map[string]memorySession),
+		groups:      make(map[string]map[string]float64),
+		buckets:     make(map[string]memoryBucket),
+		duration:    options.SessionDuration + options.GracePeriod,
+		maxSessions: options.MaxSessions,
+		codec:       options.Codec,
+		done:        make(chan struct{}),
+	}
+
+	if m.codec == nil {
+		m.codec = GobCodec{}
+	}
+
+	go m.sweep()
+	return m
+}
+
+// Close stops deleting expired entries in the background.
+func (m *MemoryStore) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+
+	return nil
+}
+
+func (m *MemoryStore) sweep() {
+	ticker := time.NewTicker(memorySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			for sessionIdStr, session := range m.sessions {
+				if !now.Before(session.expires) {
+					m.deleteSession(sessionIdStr)
+				}
+			}
+
+			for client, bucket := range m.buckets {
+				if !now.Before(bucket.expires) {
+					delete(m.buckets, client)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+// session returns the unexpired session with the given ID. The caller must
+// hold m.mu.
+func (m *MemoryStore) session(sessionIdStr string) (memorySession, bool) {
+	session, ok := m.sessions[sessionIdStr]
+	if !ok || !time.Now().Before(session.expires) {
+		return memorySession{}, false
+	}
+
+	return session, true
+}
+
+// deleteSession deletes a session and removes it from its group. The caller
+// must hold m.mu.
+func (m *MemoryStore) deleteSession(sessionIdStr string) {
+	session, ok := m.sessions[sessionIdStr]
+	if !ok {
+		return
+	}
+
+	delete(m.sessions, sessionIdStr)
+	if group, ok := m.groups[session.groupId]; ok {
+		delete(group, sessionIdStr)
+		if len(group) == 0 {
+			delete(m.groups, session.groupId)
+		}
+	}
+}
+
+func (m *MemoryStore) Session(sessionID, session interface{}) error {
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	stored, ok := m.session(sessionIdStr)
+	m.mu.Unlock()
+
+	if !ok {
+		return NoSessionFoundError
+	}
+
+	return m.codec.Unmarshal(stored.encoded, session)
+}
+
+func (m *MemoryStore) SetSession(sessionID, groupId, session interface{}) error {
+	encodedSession, err := m.codec.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return err
+	}
+
+	groupIdStr := ""
+	if groupId != nil {
+		groupIdStr, err = interfaceToString(groupId)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A reused session ID must not stay a member of its previous group.
+	m.deleteSession(sessionIdStr)
+
+	now := time.Now()
+	m.sessions[sessionIdStr] = memorySession{
+		encoded: encodedSession,
+		groupId: groupIdStr,
+		expires: now.Add(m.duration),
+	}
+
+	if groupId == nil {
+		return nil
+	}
+
+	group, ok := m.groups[groupIdStr]
+	if !ok {
+		group = make(map[string]float64)
+		m.groups[groupIdStr] = group
+	}
+	group[sessionIdStr] = float64(now.UnixNano())
+
+	// Evict the oldest sessions, other than the one just set.
+	for m.maxSessions > 0 && len(group) > m.maxSessions {
+		oldestIdStr, oldestScore := "", math.Inf(1)
+		for memberIdStr, score := range group {
+			if memberIdStr != sessionIdStr && score < oldestScore {
+				oldestIdStr, oldestScore = memberIdStr, score
+			}
+		}
+
+		m.deleteSession(oldestIdStr)
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteSession(sessionID interface{}) error {
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.deleteSession(sessionIdStr)
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *MemoryStore) InvalidateSessions(groupId interface{}) error {
+	groupIdStr, err := interfaceToString(groupId)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for sessionIdStr := range m.groups[groupIdStr] {
+		m.deleteSession(sessionIdStr)
+	}
+
+	return nil
+}
+
+// RateLimitCount counts a request by client against a token bucket, like
+// SessionStore.RateLimitCount.
+func (m *MemoryStore) RateLimitCount(client string, bucketRate, bucketCapacity float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := m.buckets[client]
+	if !ok || !now.Before(bucket.expires) {
+		bucket = memoryBucket{tokens: bucketCapacity}
+	} else if elapsed := now.UnixNano() - bucket.last; elapsed > 0 {
+		bucket.tokens = math.Min(bucketCapacity, bucket.tokens+float64(elapsed)*bucketRate)
+	}
+
+	allowed := bucket.tokens > 0
+	if allowed {
+		bucket.tokens--
+	}
+
+	// The bucket is forgotten once it would have refilled.
+	bucket.last = now.UnixNano()
+	bucket.expires = now.Add(time.Duration(math.Ceil((bucketCapacity - bucket.tokens) / bucketRate)))
+	m.buckets[client] = bucket
+
+	if !allowed {
+		return RateLimitExceededError
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestMemoryStore(t *testing.T) {
+	memoryStore := NewMemoryStore(SessionStoreOptions{
+		SessionDuration: 100 * time.Millisecond,
+		MaxSessions:     2,
+	})
+	defer memoryStore.Close()
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionIDs := make([]id.ID, 3)
+	for i := range sessionIDs {
+		sessionIDs[i], err = id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := memoryStore.SetSession(sessionIDs[i], userID, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var session int
+	if err := memoryStore.Session(sessionIDs[0], &session); err != NoSessionFoundError {
+		t.Errorf("expected the oldest session to be evicted, got %v", err)
+	}
+
+	if err := memoryStore.Session(sessionIDs[2], &session); err != nil {
+		t.Fatal(err)
+	}
+
+	if session != 2 {
+		t.Errorf("incorrect session, %d, expected %d", session, 2)
+	}
+
+	if err := memoryStore.DeleteSession(sessionIDs[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := memoryStore.Session(sessionIDs[2], &session); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError for deleted session, got %v", err)
+	}
+
+	if err := memoryStore.InvalidateSessions(userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := memoryStore.Session(sessionIDs[1], &session); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError for invalidated session, got %v", err)
+	}
+
+	if err := memoryStore.SetSession(sessionIDs[0], nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if err := memoryStore.Session(sessionIDs[0], &session); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError for expired session, got %v", err)
+	}
+
+	if err := memoryStore.Session(id.ID{}, &session); err != ZeroSessionIDError {
+		t.Errorf("expected ZeroSessionIDError, got %v", err)
+	}
+}
+
+func TestMemoryStoreRateLimit(t *testing.T) {
+	memoryStore := NewMemoryStore(SessionStoreOptions{
+		SessionDuration: time.Second,
+	})
+	defer memoryStore.Close()
+
+	rate, capacity := 3/float64(time.Hour), 3.0
+
+	for i := 0; i < 3; i++ {
+		if err := memoryStore.RateLimitCount("client", rate, capacity); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The bucket refills continuously, so allow for one more request.
+	exceeded := false
+	for i := 0; i < 2; i++ {
+		if err := memoryStore.RateLimitCount("client", rate, capacity); err == RateLimitExceededError {
+			exceeded = true
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !exceeded {
+		t.Error("rate limit not exceeded")
+	}
+
+	if err := memoryStore.RateLimitCount("other", rate, capacity); err != nil {
+		t.Errorf("rate limit shared between clients, %v", err)
+	}
+}
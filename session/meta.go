@@ -0,0 +1,120 @@
+package session
+
+import (
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Keys: sessionKey, sessionMetaKey
+// Arguments: [field, value]...
+const setSessionMeta = `
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return 0
+end
+
+redis.call('HMSET', KEYS[2], unpack(ARGV))
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+
+return 1
+`
+
+var (
+	setSessionMetaScript = redis.NewScript(2, setSessionMeta)
+)
+
+// SetSessionMeta sets metadata fields on an existing session, such as its
+// last-seen time. Metadata expires and is deleted along with the session.
+func (r *SessionStore) SetSessionMeta(sessionID interface{}, meta map[string]string) error {
+	defer r.observe("SetSessionMeta")()
+
+	if len(meta) == 0 {
+		return nil
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{r.sessionKey(sessionIdStr), r.sessionMetaKey(sessionIdStr)}
+	for field, value := range meta {
+		args = append(args, field, value)
+	}
+
+	ok, err := redis.Int(setSessionMetaScript.Do(conn, args...))
+	if err != nil {
+		return err
+	}
+
+	if ok == 0 {
+		return NoSessionFoundError
+	}
+
+	return nil
+}
+
+// SessionContext reads a session along with its group ID, metadata and
+// remaining TTL in a single round trip. The group ID is empty if the session
+// has no group.
+func (r *SessionStore) SessionContext(sessionID, session interface{}) (string, map[string]string, time.Duration, error) {
+	defer r.observe("SessionContext")()
+
+	conn := r.replicaPool.Get()
+	defer conn.Close()
+
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	conn.Send("GET", r.sessionKey(sessionIdStr))
+	conn.Send("GET", r.sessionToGroupKey(sessionIdStr))
+	conn.Send("HGETALL", r.sessionMetaKey(sessionIdStr))
+	conn.Send("PTTL", r.sessionKey(sessionIdStr))
+	if err := conn.Flush(); err != nil {
+		return "", nil, 0, err
+	}
+
+	reply, err := conn.Receive()
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	gKey, err := redis.String(conn.Receive())
+	if err != nil && err != redis.ErrNil {
+		return "", nil, 0, err
+	}
+
+	meta, err := redis.StringMap(conn.Receive())
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	ttl, err := redis.Int64(conn.Receive())
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	if reply == nil {
+		return "", nil, 0, NoSessionFoundError
+	}
+
+	parsed, err := redis.Bytes(reply, nil)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	if err := r.codec.Unmarshal(parsed, session); err != nil {
+		return "", nil, 0, err
+	}
+
+	return strings.TrimPrefix(gKey, r.groupKey("")), meta, time.Duration(ttl) * time.Millisecond, nil
+}
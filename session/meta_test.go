@@ -0,0 +1,77 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestSessionContext(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, userID, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSessionMeta(sessionID, map[string]string{"lastSeen": "now", "device": "phone"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var session string
+	group, meta, ttl, err := sessionStore.SessionContext(sessionID, &session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session != "1" {
+		t.Errorf("incorrect session, %s, expected %s", session, "1")
+	}
+
+	if group != userID.String() {
+		t.Errorf("incorrect group, %s, expected %s", group, userID.String())
+	}
+
+	if len(meta) != 2 || meta["lastSeen"] != "now" || meta["device"] != "phone" {
+		t.Errorf("incorrect meta, %v", meta)
+	}
+
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("incorrect TTL, %s", ttl)
+	}
+
+	if err := sessionStore.DeleteSession(sessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := sessionStore.SessionContext(sessionID, &session); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError, got %v", err)
+	}
+
+	if err := sessionStore.SetSessionMeta(sessionID, map[string]string{"lastSeen": "now"}); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError, got %v", err)
+	}
+}
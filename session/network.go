@@ -0,0 +1,53 @@
+package session
+
+import (
+	"context"
+	"net"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// SetSessionBound behaves like SetSession, but binds the session to the
+// allowed network. CheckSessionIP reports whether a client IP is within it.
+//
+// Binding to a single address breaks for clients behind rotating NATs or
+// roaming between mobile networks; binding to a wider subnet such as a /24
+// tolerates some of that at the cost of weaker pinning.
+func (r *SessionStore) SetSessionBound(sessionID, groupId, session interface{}, allowed *net.IPNet) error {
+	_, err := r.setSession(context.Background(), sessionID, groupId, session, nil, nil, allowed)
+	return err
+}
+
+// CheckSessionIP reports whether ip may use the session. Sessions that were
+// not bound with SetSessionBound may be used from any IP, but a session that
+// does not exist may not be used from any.
+func (r *SessionStore) CheckSessionIP(sessionID interface{}, ip net.IP) (bool, error) {
+	defer r.observe("CheckSessionIP")()
+
+	// The primary is used even with a replica, since a binding missing from
+	// a lagging replica would allow any IP.
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	allowed, err := redis.String(conn.Do("GET", r.sessionNetworkKey(sessionIdStr)))
+	if err == redis.ErrNil {
+		// An unbound session is allowed only if it exists, so that a
+		// deleted or expired session does not pass the check.
+		return redis.Bool(conn.Do("EXISTS", r.sessionKey(sessionIdStr)))
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, network, err := net.ParseCIDR(allowed)
+	if err != nil {
+		return false, err
+	}
+
+	return network.Contains(ip), nil
+}
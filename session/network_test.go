@@ -0,0 +1,88 @@
+package session
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestSessionBound(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, allowed, err := net.ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSessionBound(sessionID, nil, "1", allowed); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := sessionStore.CheckSessionIP(sessionID, net.ParseIP("192.0.2.10")); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Error("rejected IP in the bound network")
+	}
+
+	if ok, err := sessionStore.CheckSessionIP(sessionID, net.ParseIP("198.51.100.10")); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("allowed IP outside the bound network")
+	}
+
+	unboundSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(unboundSessionID, nil, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := sessionStore.CheckSessionIP(unboundSessionID, net.ParseIP("198.51.100.10")); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Error("rejected IP for an unbound session")
+	}
+
+	if err := sessionStore.DeleteSession(unboundSessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := sessionStore.CheckSessionIP(unboundSessionID, net.ParseIP("198.51.100.10")); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("allowed IP for a deleted session")
+	}
+
+	unknownSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := sessionStore.CheckSessionIP(unknownSessionID, net.ParseIP("198.51.100.10")); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("allowed IP for an unknown session")
+	}
+}
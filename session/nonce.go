@@ -0,0 +1,25 @@
+package session
+
+import (
+	"time"
+)
+
+func (r *SessionStore) nonceKey(nonce string) string {
+	return r.keyPrefix + "o" + nonce
+}
+
+// ConsumeNonce marks nonce as used for ttl. It returns false if the nonce
+// was already used.
+func (r *SessionStore) ConsumeNonce(nonce string, ttl time.Duration) (bool, error) {
+	defer r.observe("ConsumeNonce")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	reply, err := conn.Do("SET", r.nonceKey(nonce), 1, "NX", "PX", int64(ttl/time.Millisecond))
+	if err != nil {
+		return false, err
+	}
+
+	return reply != nil, nil
+}
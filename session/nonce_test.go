@@ -0,0 +1,35 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsumeNonce(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := sessionStore.ConsumeNonce("nonce", time.Minute); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Error("failed to consume unused nonce")
+	}
+
+	if ok, err := sessionStore.ConsumeNonce("nonce", time.Minute); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("consumed used nonce")
+	}
+}
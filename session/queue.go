@@ -0,0 +1,69 @@
+package session
+
+import (
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+// Keys: queue key
+// Arguments: session key prefix
+const dequeueSession = `
+while true do
+	local member = redis.call('RPOP', KEYS[1])
+	if not member then
+		return false
+	end
+
+	if redis.call('EXISTS', ARGV[1] .. member) == 1 then
+		return member
+	end
+end
+`
+
+var (
+	dequeueSessionScript = redis.NewScript(1, dequeueSession)
+)
+
+func (r *SessionStore) queueKey(queue string) string {
+	return r.keyPrefix + "q" + queue
+}
+
+// EnqueueSession appends a session to the back of queue.
+func (r *SessionStore) EnqueueSession(queue string, sessionID id.ID) error {
+	defer r.observe("EnqueueSession")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("LPUSH", r.queueKey(queue), sessionID.String()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DequeueSession removes and returns the session at the front of queue.
+// Sessions that have expired or been deleted since they were enqueued are
+// skipped, so the returned session can be read with Session. It returns
+// false if the queue is empty.
+func (r *SessionStore) DequeueSession(queue string) (id.ID, bool, error) {
+	defer r.observe("DequeueSession")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	sessionID := id.ID{}
+	reply, err := redis.Bytes(dequeueSessionScript.Do(conn, r.queueKey(queue), r.sessionKey("")))
+	if err == redis.ErrNil {
+		return sessionID, false, nil
+	}
+	if err != nil {
+		return sessionID, false, err
+	}
+
+	if err := sessionID.UnmarshalText(reply); err != nil {
+		return sessionID, false, err
+	}
+
+	return sessionID, true, nil
+}
@@ -0,0 +1,71 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestSessionQueue(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionIDs := make([]id.ID, 3)
+	for i := range sessionIDs {
+		sessionIDs[i], err = id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sessionStore.SetSession(sessionIDs[i], nil, sessionIDs[i].String()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sessionStore.EnqueueSession("work", sessionIDs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sessionID := range sessionIDs {
+		dequeuedSessionID, ok, err := sessionStore.DequeueSession("work")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ok {
+			t.Fatal("expected a queued session")
+		}
+
+		if dequeuedSessionID != sessionID {
+			t.Errorf("incorrect dequeued session, %s, expected %s", dequeuedSessionID, sessionID)
+		}
+
+		var session string
+		if err := sessionStore.Session(dequeuedSessionID, &session); err != nil {
+			t.Fatal(err)
+		}
+
+		if session != sessionID.String() {
+			t.Errorf("incorrect session, %s, expected %s", session, sessionID.String())
+		}
+	}
+
+	if _, ok, err := sessionStore.DequeueSession("work"); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("dequeued from an empty queue")
+	}
+}
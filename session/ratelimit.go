@@ -0,0 +1,80 @@
+package session
+
+import (
+	"math"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// RateLimiter counts requests against token buckets in Redis, for rate
+// limiting without a SessionStore. A SessionStore's rate limiting uses the
+// same buckets as a RateLimiter with the same Redis options.
+type RateLimiter struct {
+	pool      *redis.Pool
+	keyPrefix string
+}
+
+// NewRateLimiter returns a RateLimiter using the connection options and
+// KeyPrefix of options; the session options are ignored.
+func NewRateLimiter(options SessionStoreOptions) *RateLimiter {
+	return &RateLimiter{
+		pool:      newPool(options.Addr, options),
+		keyPrefix: options.KeyPrefix,
+	}
+}
+
+func (l *RateLimiter) rateLimitKey(client string) string {
+	return l.keyPrefix + "b" + client
+}
+
+// Allow counts a request by client against a token bucket that refills at
+// rate tokens per nanosecond up to capacity tokens, reporting whether it is
+// allowed.
+func (l *RateLimiter) Allow(client string, rate, capacity float64) (bool, error) {
+	allowed, _, _, err := l.count(client, rate, capacity)
+	return allowed, err
+}
+
+// AllowRetryAfter behaves like Allow, but also returns how long until the
+// next request by client would be allowed, zero if it would be allowed now.
+func (l *RateLimiter) AllowRetryAfter(client string, rate, capacity float64) (bool, time.Duration, error) {
+	allowed, _, retryAfter, err := l.count(client, rate, capacity)
+	return allowed, retryAfter, err
+}
+
+// count counts a request by client, returning whether it is allowed, the
+// tokens remaining and how long until the next request would be allowed.
+func (l *RateLimiter) count(client string, rate, capacity float64) (bool, float64, time.Duration, error) {
+	conn := l.pool.Get()
+	defer conn.Close()
+
+	res, err := redis.Values(tokenBucketScript.Do(conn, l.rateLimitKey(client), rate, capacity, time.Now().UnixNano()))
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	var ok int
+	var tokens float64
+	if _, err := redis.Scan(res, &ok, &tokens); err != nil {
+		return false, 0, 0, err
+	}
+
+	// A request is allowed while any tokens remain, so the next one waits
+	// until the balance is positive again.
+	var retryAfter time.Duration
+	if tokens <= 0 {
+		retryAfter = time.Duration(-tokens/rate) + 1
+	}
+
+	return ok == 1, math.Max(0, tokens), retryAfter, nil
+}
+
+// wouldAllow reports whether n requests by client would all be allowed now,
+// without counting any of them.
+func (l *RateLimiter) wouldAllow(client string, rate, capacity, n float64) (bool, error) {
+	conn := l.pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(tokenBucketWouldAllowScript.Do(conn, l.rateLimitKey(client), rate, capacity, time.Now().UnixNano(), n))
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rateLimiter := NewRateLimiter(SessionStoreOptions{
+		Addr: ":6379",
+	})
+
+	conn := rateLimiter.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	rate, capacity := 3/float64(time.Hour), 3.0
+
+	for i := 0; i < 3; i++ {
+		if allowed, err := rateLimiter.Allow("client", rate, capacity); err != nil {
+			t.Fatal(err)
+		} else if !allowed {
+			t.Errorf("request %d not allowed", i+1)
+		}
+	}
+
+	// A session store with the same options shares the bucket. It refills
+	// continuously, so allow for one more request.
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exceeded := false
+	for i := 0; i < 2; i++ {
+		if err := sessionStore.RateLimitCount("client", rate, capacity); err == RateLimitExceededError {
+			exceeded = true
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !exceeded {
+		t.Error("rate limit not shared with session store")
+	}
+
+	if allowed, err := rateLimiter.Allow("other", rate, capacity); err != nil {
+		t.Fatal(err)
+	} else if !allowed {
+		t.Error("rate limit shared between clients")
+	}
+
+	allowed, retryAfter, err := rateLimiter.AllowRetryAfter("client", rate, capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if allowed || retryAfter <= 0 || retryAfter > 20*time.Minute {
+		t.Errorf("incorrect result for exhausted bucket, allowed %t, retry after %s", allowed, retryAfter)
+	}
+}
@@ -0,0 +1,85 @@
+package session
+
+import (
+	"errors"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Keys: sessionKey, sessionToGroupKey, sessionMetaKey, sessionNetworkKey of
+// the old session ID, then of the new session ID
+// Arguments: old sessionId, new sessionId
+const rekeySession = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+
+if redis.call('EXISTS', KEYS[5]) == 1 then
+	return -2
+end
+
+redis.call('DEL', KEYS[6], KEYS[7], KEYS[8])
+for i = 1, 4 do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		redis.call('RENAME', KEYS[i], KEYS[i + 4])
+	end
+end
+
+local groupKey = redis.call('GET', KEYS[6])
+if groupKey then
+	local score = redis.call('ZSCORE', groupKey, ARGV[1])
+	redis.call('ZREM', groupKey, ARGV[1])
+	if score then
+		redis.call('ZADD', groupKey, score, ARGV[2])
+	end
+end
+
+return 1
+`
+
+var (
+	SessionExistsError = errors.New("session already exists")
+	rekeySessionScript = redis.NewScript(8, rekeySession)
+)
+
+// RekeySession atomically moves a session to a new ID, keeping its payload,
+// TTL, metadata and place in its group. The old ID stops being valid at the
+// same moment the new one starts. It returns SessionExistsError if a session
+// already exists with the new ID.
+//
+// Rekeying with a fresh ID when a session gains privileges, such as at login,
+// prevents session fixation: an ID planted or observed before then stops
+// working.
+func (r *SessionStore) RekeySession(oldSessionID, newSessionID interface{}) error {
+	defer r.observe("RekeySession")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	oldIdStr, err := sessionIDToString(oldSessionID)
+	if err != nil {
+		return err
+	}
+
+	newIdStr, err := sessionIDToString(newSessionID)
+	if err != nil {
+		return err
+	}
+
+	rekeyed, err := redis.Int(rekeySessionScript.Do(conn,
+		r.sessionKey(oldIdStr), r.sessionToGroupKey(oldIdStr), r.sessionMetaKey(oldIdStr), r.sessionNetworkKey(oldIdStr),
+		r.sessionKey(newIdStr), r.sessionToGroupKey(newIdStr), r.sessionMetaKey(newIdStr), r.sessionNetworkKey(newIdStr),
+		oldIdStr, newIdStr))
+	if err != nil {
+		return err
+	}
+
+	switch rekeyed {
+	case -1:
+		return NoSessionFoundError
+	case -2:
+		return SessionExistsError
+	}
+
+	return nil
+}
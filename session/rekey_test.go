@@ -0,0 +1,114 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestRekeySession(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(oldSessionID, userID, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSessionMeta(oldSessionID, map[string]string{"device": "phone"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shorten the TTL so that it is distinguishable from a fresh session.
+	if _, err := conn.Do("PEXPIRE", "s"+oldSessionID.String(), 30000); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.RekeySession(oldSessionID, newSessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	var session string
+	if err := sessionStore.Session(oldSessionID, &session); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError for the old ID, got %v", err)
+	}
+
+	group, meta, ttl, err := sessionStore.SessionContext(newSessionID, &session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session != "1" {
+		t.Errorf("incorrect session, %s, expected %s", session, "1")
+	}
+
+	if group != userID.String() {
+		t.Errorf("incorrect group, %s, expected %s", group, userID.String())
+	}
+
+	if meta["device"] != "phone" {
+		t.Errorf("incorrect meta, %v", meta)
+	}
+
+	if ttl <= 25*time.Second || ttl > 30*time.Second {
+		t.Errorf("incorrect TTL, %s, expected about 30s", ttl)
+	}
+
+	// TODO: get the group key some other way
+	res, err := redis.Strings(conn.Do("ZRANGE", "g"+userID.String(), 0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0] != newSessionID.String() {
+		t.Errorf("expected only the new session in group, got %v", res)
+	}
+
+	if err := sessionStore.RekeySession(oldSessionID, newSessionID); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError, got %v", err)
+	}
+
+	otherSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(otherSessionID, userID, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.RekeySession(otherSessionID, newSessionID); err != SessionExistsError {
+		t.Errorf("expected SessionExistsError, got %v", err)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,86 @@
+package session
+
+import (
+	"strings"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+const scanCount = 100
+
+// globEscaper escapes the special characters of a SCAN MATCH pattern.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// scanSessions calls fn with the ID of every session in the store, stopping
+// at the first error. A session may be seen more than once if keys are
+// added or removed during the scan.
+func (r *SessionStore) scanSessions(conn redis.Conn, fn func(sessionIdStr string) error) error {
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", globEscaper.Replace(r.sessionKey(""))+"*", "COUNT", scanCount))
+		if err != nil {
+			return err
+		}
+
+		cursor, err = redis.Int(values[0], nil)
+		if err != nil {
+			return err
+		}
+
+		keys, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := fn(strings.TrimPrefix(key, r.sessionKey(""))); err != nil {
+				return err
+			}
+		}
+
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// InvalidateWhere deletes every session for which predicate returns true,
+// returning the number deleted. predicate is passed the encoded session.
+// This scans and reads the entire keyspace, so it is expensive on large
+// stores. Sessions whose IDs are not id.IDs are skipped.
+func (r *SessionStore) InvalidateWhere(predicate func(sessionID id.ID, payload []byte) bool) (int, error) {
+	defer r.observe("InvalidateWhere")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	count := 0
+	err := r.scanSessions(conn, func(sessionIdStr string) error {
+		sessionID := id.ID{}
+		if err := sessionID.UnmarshalText([]byte(sessionIdStr)); err != nil {
+			return nil
+		}
+
+		payload, err := redis.Bytes(conn.Do("GET", r.sessionKey(sessionIdStr)))
+		if err == redis.ErrNil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !predicate(sessionID, payload) {
+			return nil
+		}
+
+		if err := r.deleteSession(conn, sessionIdStr); err != nil {
+			return err
+		}
+
+		count++
+		return nil
+	})
+
+	return count, err
+}
@@ -0,0 +1,91 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestInvalidateWhere(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := []string{"trial", "paid", "trial", "paid"}
+	sessionIDs := make([]id.ID, len(roles))
+	for i, role := range roles {
+		sessionIDs[i], err = id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		groupID := interface{}(userID)
+		if i == 0 {
+			groupID = nil
+		}
+
+		if err := sessionStore.SetSession(sessionIDs[i], groupID, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := sessionStore.InvalidateWhere(func(sessionID id.ID, payload []byte) bool {
+		var role string
+		if err := gob.NewDecoder(bytes.NewBuffer(payload)).Decode(&role); err != nil {
+			t.Error(err)
+		}
+
+		return role == "trial"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("Expected 2 sessions invalidated, got %d", count)
+	}
+
+	for i, sessionID := range sessionIDs {
+		var role string
+		err := sessionStore.Session(sessionID, &role)
+		if roles[i] == "trial" && err != NoSessionFoundError {
+			t.Errorf("Expected session %s to be invalidated, got %v", sessionID, err)
+		}
+		if roles[i] == "paid" && err != nil {
+			t.Error(err)
+		}
+	}
+
+	// TODO: get the group key some other way
+	res, err := redis.Strings(conn.Do("ZRANGE", "g"+userID.String(), 0, -1))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(res) != 2 {
+		t.Errorf("Expected 2 sessions in group, got %d: %v", len(res), res)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,16 +1,23 @@
 package session
 
 import (
-	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding"
-	"encoding/gob"
 	"errors"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/O-C-R/auth/id"
 	"github.com/garyburd/redigo/redis"
 )
 
-// Arguments: current unix timestamp (nanoseconds), rate (tokens per nanosecond), bucket capacity.
+// Keys: bucket
+// Arguments: rate (tokens per nanosecond), bucket capacity, current unix timestamp (nanoseconds)
+// Returns whether the request is allowed, the tokens left as a string, and
+// the milliseconds until the bucket is full again.
 const tokenBucket = `
 local bucket = redis.call('hmget', KEYS[1], '1', '2')
 if(not bucket[1]) then
@@ -19,8 +26,11 @@ end
 
 if(not bucket[2]) then
 	bucket[2] = tonumber(ARGV[2])
-elseif(ARGV[3] > bucket[1]) then
-	bucket[2] = math.min(ARGV[2], bucket[2] + (ARGV[3] - bucket[1]) * ARGV[1])
+else
+	bucket[2] = tonumber(bucket[2])
+	if(ARGV[3] > bucket[1]) then
+		bucket[2] = math.min(ARGV[2], bucket[2] + (ARGV[3] - bucket[1]) * ARGV[1])
+	end
 end
 
 local ok = 0
@@ -30,75 +40,183 @@ if(bucket[2]>0) then
 end
 
 redis.call('hmset', KEYS[1], '1', ARGV[3], '2', bucket[2])
-redis.call('pexpire', KEYS[1], math.ceil((ARGV[2] - bucket[2]) / ARGV[1] / 1e3))
+local full = math.ceil((ARGV[2] - bucket[2]) / ARGV[1] / 1e6)
+redis.call('pexpire', KEYS[1], full)
+
+return {ok, tostring(bucket[2]), full}
+`
+
+// Keys: bucket
+// Arguments: rate (tokens per nanosecond), bucket capacity, current unix timestamp (nanoseconds), requests
+// Reads the bucket without modifying it.
+const tokenBucketWouldAllow = `
+local bucket = redis.call('hmget', KEYS[1], '1', '2')
+local capacity = tonumber(ARGV[2])
+local tokens = capacity
+if bucket[2] then
+	tokens = tonumber(bucket[2])
+	local elapsed = tonumber(ARGV[3]) - tonumber(bucket[1])
+	if elapsed > 0 then
+		tokens = math.min(capacity, tokens + elapsed * tonumber(ARGV[1]))
+	end
+end
+
+-- Each request is allowed while any tokens remain.
+if tokens > tonumber(ARGV[4]) - 1 then
+	return 1
+end
 
-return ok
+return 0
 `
 
-// Keys: sorted set name
-// Arguments: max length, [timestamp, member]...
+// Keys: sorted set name, max length override
+// Arguments: max length, protected member, prefix count, [prefix]..., [timestamp, member]...
+// Returns the evicted members, whose prefixed keys are deleted.
 const addToCappedSortedSet = `
-local desiredSize = tonumber(ARGV[1])
+local desiredSize = tonumber(redis.call('GET', KEYS[2]) or ARGV[1])
+local prefixCount = tonumber(ARGV[3])
+local first = 4 + prefixCount
+
+-- Members being added and the protected member are never evicted
+local exempt = {}
+exempt[ARGV[2]] = true
+for i = first + 1, #ARGV, 2 do
+	exempt[ARGV[i]] = true
+end
 
--- Swap in the key to the first param to zadd
-ARGV[1] = KEYS[1]
-redis.call('ZADD', unpack(ARGV))
+redis.call('ZADD', KEYS[1], unpack(ARGV, first))
 
+local toDelete = {}
 if desiredSize > 0 then
 	local size = redis.call('ZCARD', KEYS[1])
-	local maxRank = size - desiredSize - 1
-	if maxRank >= 0 then
-		local deleted = redis.call('ZREMRANGEBYRANK', KEYS[1], 0, maxRank)
-		return deleted
+	local excess = size - desiredSize
+	if excess > 0 then
+		local candidates = redis.call('ZRANGE', KEYS[1], 0, -1)
+		for idx, member in ipairs(candidates) do
+			if #toDelete >= excess then
+				break
+			end
+			if not exempt[member] then
+				table.insert(toDelete, member)
+			end
+		end
+	end
+end
+
+if #toDelete > 0 then
+	redis.call('ZREM', KEYS[1], unpack(toDelete))
+	local keys = {}
+	for midx, member in ipairs(toDelete) do
+		for pidx = 4, first - 1 do
+			table.insert(keys, ARGV[pidx] .. member)
+		end
+	end
+	if #keys > 0 then
+		redis.call('DEL', unpack(keys))
 	end
 end
 
+return toDelete
+`
+
+// Keys: sessionToGroupKey
+// Arguments: new group key (empty if none), sessionId
+const regroupSession = `
+local previousGroupKey = redis.call('GET', KEYS[1])
+if previousGroupKey and previousGroupKey ~= ARGV[1] then
+	redis.call('ZREM', previousGroupKey, ARGV[2])
+	redis.call('DEL', KEYS[1])
+	return 1
+end
+
 return 0
 `
 
-// Keys: sessionKey, sessionToGroupKey
+// Keys: sessionKey, sessionToGroupKey, sessionMetaKey, sessionNetworkKey
 // Arguments: sessionId
 const deleteSingleSession = `
-redis.call('DEL', KEYS[1])
+redis.call('DEL', KEYS[1], KEYS[3], KEYS[4])
 local groupKey = redis.call('GET', KEYS[2])
+if not groupKey then
+	return 0
+end
+
 redis.call('DEL', KEYS[2])
 local deleted = redis.call('ZREM', groupKey, ARGV[1])
 
 return deleted
 `
 
-// Keys: sorted set name
-// Arguments: prefixes
-const deleteSortedSetAndKeys = `
-local members = redis.call('ZRANGE', KEYS[1], 0, -1)
+// Keys: sessionKey, sessionToGroupKey, sessionMetaKey, sessionNetworkKey
+// Arguments: milliseconds to extend by
+const extendSession = `
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	return ttl
+end
 
-local toDelete = {}
-local count = 0
-for midx, member in ipairs(members) do
-	for pidx, prefix in ipairs(ARGV) do
-		table.insert(toDelete, prefix .. member)
-		count = count + 1
+local extended = ttl + tonumber(ARGV[1])
+redis.call('PEXPIRE', KEYS[1], extended)
+for i = 2, #KEYS do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		redis.call('PEXPIRE', KEYS[i], extended)
 	end
 end
 
-if count > 0 then
-	redis.call('del', unpack(toDelete))
+return extended
+`
+
+// Keys: sessionKey, sessionToGroupKey, sessionMetaKey, sessionNetworkKey
+// Arguments: floor milliseconds, new TTL milliseconds
+const touchSessionIfAbove = `
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return -1
 end
 
-redis.call('del', KEYS[1])
+if ttl >= 0 and ttl < tonumber(ARGV[1]) then
+	return 0
+end
 
-return 0
+for i = 1, #KEYS do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		redis.call('PEXPIRE', KEYS[i], ARGV[2])
+	end
+end
+
+return 1
 `
 
 var (
-	InvalidStringError           = errors.New("Must provide a string-like object")
-	NoSessionFoundError          = errors.New("No session found")
-	RateLimitExceededError       = errors.New("rate limit exceeded")
-	redisError                   = errors.New("redis error")
-	tokenBucketScript            = redis.NewScript(1, tokenBucket)
-	addToCappedSortedSetScript   = redis.NewScript(1, addToCappedSortedSet)
-	deleteSingleSessionScript    = redis.NewScript(2, deleteSingleSession)
-	deleteSortedSetAndKeysScript = redis.NewScript(1, deleteSortedSetAndKeys)
+	InvalidStringError          = errors.New("Must provide a string-like object")
+	NoSessionFoundError         = errors.New("No session found")
+	ZeroSessionIDError          = errors.New("zero session ID")
+	RateLimitExceededError      = errors.New("rate limit exceeded")
+	RateLimitNotConfiguredError = errors.New("rate limit not configured")
+	redisError                  = errors.New("redis error")
+	tokenBucketScript           = redis.NewScript(1, tokenBucket)
+	tokenBucketWouldAllowScript = redis.NewScript(1, tokenBucketWouldAllow)
+	addToCappedSortedSetScript  = redis.NewScript(2, addToCappedSortedSet)
+	deleteSingleSessionScript   = redis.NewScript(4, deleteSingleSession)
+	extendSessionScript         = redis.NewScript(4, extendSession)
+	regroupSessionScript        = redis.NewScript(1, regroupSession)
+	touchSessionIfAboveScript   = redis.NewScript(4, touchSessionIfAbove)
+
+	// scripts are loaded by loadScripts.
+	scripts = []*redis.Script{
+		tokenBucketScript,
+		tokenBucketWouldAllowScript,
+		addToCappedSortedSetScript,
+		deleteSingleSessionScript,
+		extendSessionScript,
+		regroupSessionScript,
+		touchSessionIfAboveScript,
+		unlockScript,
+		consumeChallengeScript,
+		setSessionMetaScript,
+		dequeueSessionScript,
+		rekeySessionScript,
+	}
 )
 
 func interfaceToString(v interface{}) (string, error) {
@@ -118,52 +236,157 @@ func interfaceToString(v interface{}) (string, error) {
 	}
 }
 
-func sessionKey(sessionID string) string {
-	return "s" + sessionID
+// sessionIDToString is interfaceToString for session IDs, rejecting the zero
+// id.ID so that an uninitialized ID can never name a session.
+func sessionIDToString(sessionID interface{}) (string, error) {
+	if sessionID, ok := sessionID.(id.ID); ok && sessionID.IsZero() {
+		return "", ZeroSessionIDError
+	}
+
+	return interfaceToString(sessionID)
+}
+
+func (r *SessionStore) sessionKey(sessionID string) string {
+	return r.keyPrefix + "s" + sessionID
+}
+
+func (r *SessionStore) sessionToGroupKey(sessionID string) string {
+	return r.keyPrefix + "z" + sessionID
 }
 
-func sessionToGroupKey(sessionID string) string {
-	return "z" + sessionID
+func (r *SessionStore) groupKey(groupId string) string {
+	return r.keyPrefix + "g" + groupId
 }
 
-func groupKey(groupId string) string {
-	return "g" + groupId
+func (r *SessionStore) sessionMetaKey(sessionID string) string {
+	return r.keyPrefix + "m" + sessionID
 }
 
-func rateLimitKey(client string) string {
-	return "b" + client
+func (r *SessionStore) sessionNetworkKey(sessionID string) string {
+	return r.keyPrefix + "n" + sessionID
+}
+
+func (r *SessionStore) cappedSortedSetArgs(groupIdStr string, maxLength int, protected string, scoreMembers ...interface{}) []interface{} {
+	prefixes := r.sessionKeyPrefixes()
+	args := []interface{}{r.groupKey(groupIdStr), r.groupLimitKey(groupIdStr), maxLength, protected, len(prefixes)}
+	args = append(args, prefixes...)
+	return append(args, scoreMembers...)
+}
+
+// sessionKeyPrefixes returns the prefixes of every per-session key.
+func (r *SessionStore) sessionKeyPrefixes() []interface{} {
+	return []interface{}{r.sessionKey(""), r.sessionToGroupKey(""), r.sessionMetaKey(""), r.sessionNetworkKey("")}
 }
 
 type SessionStoreOptions struct {
-	Addr, Password  string
+	Addr, Password string
+
+	// DB is the index of the Redis database to use.
+	DB int
+
+	// KeyPrefix is prepended to every key the store uses, so that several
+	// stores can share a Redis database.
+	KeyPrefix string
+
+	// TLSConfig, if not nil, makes connections to Redis use TLS. If its
+	// ServerName is empty, certificates are verified against the host of
+	// Addr (or ReplicaAddr). Password is sent over the TLS connection.
+	TLSConfig *tls.Config
+
+	// MaxIdle and IdleTimeout bound the idle connections kept open to each
+	// server, defaulting to 3 and 5 minutes. MaxActive limits the number of
+	// open connections, which is unlimited if 0. If Wait is set, operations
+	// wait for a connection when MaxActive is reached rather than failing.
+	MaxIdle, MaxActive int
+	IdleTimeout        time.Duration
+	Wait               bool
+
+	// ReplicaAddr, if set, is the address of a read replica used for
+	// read-only operations. Because of replication lag, a session may not be
+	// readable from the replica immediately after it is written. Checks that
+	// enforce a restriction, such as CheckSessionIP, always use the primary,
+	// since a lagging replica would let them fail open.
+	ReplicaAddr string
+
+	// Codec encodes sessions for storage. It defaults to GobCodec.
+	Codec Codec
+
 	SessionDuration time.Duration
 	MaxSessions     int
+
+	// RateLimit and RateLimitDuration configure RateLimit to allow bursts of
+	// up to RateLimit requests, refilled at RateLimit per RateLimitDuration.
+	RateLimit         int
+	RateLimitDuration time.Duration
+
+	// GracePeriod keeps sessions readable for this long past their nominal
+	// expiry. SessionStale reports whether a session is within the grace
+	// period.
+	GracePeriod time.Duration
+
+	// LoadScripts loads the store's scripts when it is created, failing if
+	// Redis is unreachable. Otherwise nothing connects to Redis until the
+	// store is used.
+	LoadScripts bool
+
+	// SlowLog, if not nil, is called with the name and duration of any
+	// operation that takes longer than SlowThreshold.
+	SlowLog       func(op string, d time.Duration)
+	SlowThreshold time.Duration
+
+	// RecordLatency keeps a histogram of the latency of each operation,
+	// reported by LatencyStats.
+	RecordLatency bool
+
+	// OnEvict, if not nil, is called with each session evicted from a group
+	// because it exceeded MaxSessions, after the session is deleted. It runs
+	// synchronously in the call that caused the eviction, such as SetSession,
+	// so it should not block. Evicted session IDs that are not id.IDs are not
+	// reported.
+	OnEvict func(sessionID id.ID, groupId string)
 }
 
 type SessionStore struct {
-	pool                                          *redis.Pool
+	pool, replicaPool                             *redis.Pool
 	sessionDuration, rateLimitDuration, rateLimit int64
+	gracePeriod                                   int64
 	maxSessions                                   int
+	slowLog                                       func(op string, d time.Duration)
+	slowThreshold                                 time.Duration
+	codec                                         Codec
+	latencies                                     *latencyStats
+	limiter                                       *RateLimiter
+	onEvict                                       func(sessionID id.ID, groupId string)
+	db                                            int
+	keyPrefix                                     string
+	scriptsMu                                     sync.Mutex
+	scriptsLoaded                                 bool
 }
 
-func NewSessionStore(options SessionStoreOptions) (*SessionStore, error) {
-	pool := &redis.Pool{
-		MaxIdle:     3,
-		IdleTimeout: 5 * time.Minute,
-		Dial: func() (redis.Conn, error) {
-			conn, err := redis.Dial("tcp", options.Addr)
-			if err != nil {
-				return nil, err
-			}
+func newPool(addr string, options SessionStoreOptions) *redis.Pool {
+	// The password is sent before the database is selected.
+	dialOptions := []redis.DialOption{redis.DialPassword(options.Password), redis.DialDatabase(options.DB)}
+	if options.TLSConfig != nil {
+		dialOptions = append(dialOptions, redis.DialUseTLS(true), redis.DialTLSConfig(options.TLSConfig))
+	}
 
-			if options.Password != "" {
-				if _, err := conn.Do("AUTH", options.Password); err != nil {
-					conn.Close()
-					return nil, err
-				}
-			}
+	maxIdle := options.MaxIdle
+	if maxIdle == 0 {
+		maxIdle = 3
+	}
+
+	idleTimeout := options.IdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = 5 * time.Minute
+	}
 
-			return conn, err
+	return &redis.Pool{
+		MaxIdle:     maxIdle,
+		MaxActive:   options.MaxActive,
+		IdleTimeout: idleTimeout,
+		Wait:        options.Wait,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr, dialOptions...)
 		},
 		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
 			if time.Since(t) < time.Minute {
@@ -174,41 +397,134 @@ func NewSessionStore(options SessionStoreOptions) (*SessionStore, error) {
 			return err
 		},
 	}
+}
+
+func NewSessionStore(options SessionStoreOptions) (*SessionStore, error) {
+	pool := newPool(options.Addr, options)
+
+	replicaPool := pool
+	if options.ReplicaAddr != "" {
+		replicaPool = newPool(options.ReplicaAddr, options)
+	}
+
+	sessionStore := &SessionStore{
+		pool:              pool,
+		replicaPool:       replicaPool,
+		sessionDuration:   int64(options.SessionDuration / time.Second),
+		rateLimitDuration: int64(options.RateLimitDuration),
+		rateLimit:         int64(options.RateLimit),
+		gracePeriod:       int64(options.GracePeriod / time.Second),
+		maxSessions:       options.MaxSessions,
+		slowLog:           options.SlowLog,
+		slowThreshold:     options.SlowThreshold,
+		onEvict:           options.OnEvict,
+		codec:             options.Codec,
+		db:                options.DB,
+		keyPrefix:         options.KeyPrefix,
+	}
+
+	// Rate limiting shares the primary pool.
+	sessionStore.limiter = &RateLimiter{pool: pool, keyPrefix: options.KeyPrefix}
+
+	if sessionStore.codec == nil {
+		sessionStore.codec = GobCodec{}
+	}
+
+	if options.RecordLatency {
+		sessionStore.latencies = newLatencyStats()
+	}
 
-	conn := pool.Get()
+	if options.LoadScripts {
+		if err := sessionStore.loadScripts(); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessionStore, nil
+}
+
+// loadScripts loads the store's scripts into Redis once. Scripts run with Do
+// fall back to EVAL if they are not loaded, so loading is an optimization
+// and a connectivity check rather than a requirement. A failed load is
+// retried on the next call.
+func (r *SessionStore) loadScripts() error {
+	r.scriptsMu.Lock()
+	defer r.scriptsMu.Unlock()
+
+	if r.scriptsLoaded {
+		return nil
+	}
+
+	conn := r.pool.Get()
 	defer conn.Close()
 
-	// Load scripts
-	if err := tokenBucketScript.Load(conn); err != nil {
-		return nil, err
+	for _, script := range scripts {
+		if err := script.Load(conn); err != nil {
+			return err
+		}
 	}
-	if err := addToCappedSortedSetScript.Load(conn); err != nil {
-		return nil, err
+
+	r.scriptsLoaded = true
+	return nil
+}
+
+func noop() {}
+
+// observe starts timing op, returning a function that records it and
+// reports it to the slow log if it exceeded the slow threshold.
+func (r *SessionStore) observe(op string) func() {
+	if r.slowLog == nil && r.latencies == nil {
+		return noop
 	}
-	if err := deleteSingleSessionScript.Load(conn); err != nil {
-		return nil, err
+
+	start := time.Now()
+	return func() {
+		d := time.Since(start)
+		if r.latencies != nil {
+			r.latencies.record(op, d)
+		}
+
+		if r.slowLog != nil && d > r.slowThreshold {
+			r.slowLog(op, d)
+		}
 	}
-	if err := deleteSortedSetAndKeysScript.Load(conn); err != nil {
-		return nil, err
+}
+
+// Ping checks that the primary Redis server is reachable, for use in health
+// checks. It gives up once ctx is done.
+func (r *SessionStore) Ping(ctx context.Context) error {
+	defer r.observe("Ping")()
+
+	conn, err := getContext(ctx, r.pool)
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
 
-	return &SessionStore{
-		pool:            pool,
-		sessionDuration: int64(options.SessionDuration / time.Second),
-		maxSessions:     options.MaxSessions,
-	}, nil
+	_, err = conn.Do("PING")
+	return err
 }
 
 func (r *SessionStore) Session(sessionID, session interface{}) error {
-	conn := r.pool.Get()
+	return r.SessionWithContext(context.Background(), sessionID, session)
+}
+
+// SessionWithContext behaves like Session, but gives up once ctx is done.
+func (r *SessionStore) SessionWithContext(ctx context.Context, sessionID, session interface{}) error {
+	defer r.observe("Session")()
+
+	conn, err := getContext(ctx, r.replicaPool)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
-	sessionIdStr, err := interfaceToString(sessionID)
+	sessionIdStr, err := sessionIDToString(sessionID)
 	if err != nil {
 		return err
 	}
 
-	reply, err := conn.Do("GET", sessionKey(sessionIdStr))
+	reply, err := conn.Do("GET", r.sessionKey(sessionIdStr))
 	if err != nil {
 		return err
 	}
@@ -223,108 +539,544 @@ func (r *SessionStore) Session(sessionID, session interface{}) error {
 		return err
 	}
 
-	return gob.NewDecoder(bytes.NewBuffer(parsed)).Decode(session)
+	return r.codec.Unmarshal(parsed, session)
 }
 
-func (r *SessionStore) SetSession(sessionID, groupId, session interface{}) error {
-	conn := r.pool.Get()
+// SessionStale behaves like Session, but also reports whether the session is
+// past its nominal expiry and only being returned because of the grace
+// period. The nominal expiry is derived from the remaining TTL, which
+// includes the grace period.
+func (r *SessionStore) SessionStale(sessionID, session interface{}) (bool, error) {
+	defer r.observe("SessionStale")()
+
+	conn := r.replicaPool.Get()
 	defer conn.Close()
 
-	encodedSession := bytes.NewBuffer([]byte{})
-	if err := gob.NewEncoder(encodedSession).Encode(session); err != nil {
-		return err
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	conn.Send("GET", r.sessionKey(sessionIdStr))
+	conn.Send("PTTL", r.sessionKey(sessionIdStr))
+	if err := conn.Flush(); err != nil {
+		return false, err
 	}
 
-	sessionIdStr, err := interfaceToString(sessionID)
+	reply, err := conn.Receive()
 	if err != nil {
-		return err
+		return false, err
 	}
-	sKey := sessionKey(sessionIdStr)
 
-	conn.Send("MULTI")
+	ttl, err := redis.Int64(conn.Receive())
+	if err != nil {
+		return false, err
+	}
 
-	if err := conn.Send("SETEX", sKey, r.sessionDuration, encodedSession); err != nil {
-		return err
+	if reply == nil {
+		return false, NoSessionFoundError
+	}
+
+	parsed, err := redis.Bytes(reply, err)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.codec.Unmarshal(parsed, session); err != nil {
+		return false, err
+	}
+
+	return ttl >= 0 && ttl < r.gracePeriod*1000, nil
+}
+
+func (r *SessionStore) SetSession(sessionID, groupId, session interface{}) error {
+	return r.SetSessionWithContext(context.Background(), sessionID, groupId, session)
+}
+
+// SetSessionWithContext behaves like SetSession, but gives up once ctx is
+// done.
+func (r *SessionStore) SetSessionWithContext(ctx context.Context, sessionID, groupId, session interface{}) error {
+	_, err := r.setSession(ctx, sessionID, groupId, session, nil, nil, nil)
+	return err
+}
+
+// SetSessionProtected behaves like SetSession, but the session identified by
+// protectedSessionID is exempt from eviction when the group is capped. This
+// keeps the session authorizing the request from being evicted by it.
+func (r *SessionStore) SetSessionProtected(sessionID, groupId, session, protectedSessionID interface{}) error {
+	_, err := r.setSession(context.Background(), sessionID, groupId, session, protectedSessionID, nil, nil)
+	return err
+}
+
+// SetSessionEvicted behaves like SetSession, but returns the IDs of any
+// sessions evicted from the group because it exceeded MaxSessions. Evicted
+// sessions are deleted. Session IDs in the group must be id.IDs.
+func (r *SessionStore) SetSessionEvicted(sessionID, groupId, session interface{}) ([]id.ID, error) {
+	evicted, err := r.setSession(context.Background(), sessionID, groupId, session, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	evictedIDs := make([]id.ID, len(evicted))
+	for i, evictedIdStr := range evicted {
+		if err := evictedIDs[i].UnmarshalText([]byte(evictedIdStr)); err != nil {
+			return nil, err
+		}
+	}
+
+	return evictedIDs, nil
+}
+
+// SetSessionScored behaves like SetSession, but gives the session an eviction
+// score in place of the time it was set; when the group exceeds MaxSessions,
+// the lowest-scored sessions are evicted first. Sessions set without a score
+// are scored by nanoseconds since the Unix epoch, so callers mixing the two
+// should choose scores on the same scale.
+func (r *SessionStore) SetSessionScored(sessionID, groupId, session interface{}, score float64) error {
+	_, err := r.setSession(context.Background(), sessionID, groupId, session, nil, score, nil)
+	return err
+}
+
+func (r *SessionStore) setSession(ctx context.Context, sessionID, groupId, session, protectedSessionID, score interface{}, allowed *net.IPNet) ([]string, error) {
+	defer r.observe("SetSession")()
+
+	conn, err := getContext(ctx, r.pool)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	encodedSession, err := r.codec.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return nil, err
 	}
+	sKey := r.sessionKey(sessionIdStr)
+	sgKey := r.sessionToGroupKey(sessionIdStr)
 
+	gKey, groupIdStr := "", ""
 	if groupId != nil {
-		groupIdStr, err := interfaceToString(groupId)
+		groupIdStr, err = interfaceToString(groupId)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		gKey = r.groupKey(groupIdStr)
+	}
 
-		gKey := groupKey(groupIdStr)
-		sgKey := sessionToGroupKey(sessionIdStr)
+	// Sessions are evicted oldest first unless given a score.
+	if score == nil {
+		score = time.Now().UnixNano()
+	}
 
-		if err := conn.Send("SETEX", sgKey, r.sessionDuration, gKey); err != nil {
-			return err
+	protectedIdStr := ""
+	if protectedSessionID != nil {
+		protectedIdStr, err = interfaceToString(protectedSessionID)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if err := addToCappedSortedSetScript.Send(conn, gKey, r.maxSessions, time.Now().UnixNano(), sessionIdStr); err != nil {
-			return err
+	conn.Send("MULTI")
+
+	if err := conn.Send("SETEX", sKey, r.sessionDuration+r.gracePeriod, encodedSession); err != nil {
+		return nil, err
+	}
+
+	if err := conn.Send("EXPIRE", r.sessionMetaKey(sessionIdStr), r.sessionDuration+r.gracePeriod); err != nil {
+		return nil, err
+	}
+
+	// A session stays bound to its network until it is rebound.
+	if allowed != nil {
+		if err := conn.Send("SETEX", r.sessionNetworkKey(sessionIdStr), r.sessionDuration+r.gracePeriod, allowed.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := conn.Send("EXPIRE", r.sessionNetworkKey(sessionIdStr), r.sessionDuration+r.gracePeriod); err != nil {
+			return nil, err
+		}
+	}
+
+	// A reused session ID must not stay a member of its previous group.
+	if err := regroupSessionScript.Send(conn, sgKey, gKey, sessionIdStr); err != nil {
+		return nil, err
+	}
+
+	if gKey != "" {
+		if err := conn.Send("SETEX", sgKey, r.sessionDuration+r.gracePeriod, gKey); err != nil {
+			return nil, err
+		}
+
+		if err := addToCappedSortedSetScript.Send(conn, r.cappedSortedSetArgs(groupIdStr, r.maxSessions, protectedIdStr, score, sessionIdStr)...); err != nil {
+			return nil, err
 		}
 	}
 
 	res, err := redis.Values(conn.Do("EXEC"))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for _, elem := range res {
 		if err, ok := elem.(error); ok {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	if gKey == "" {
+		return nil, nil
+	}
+
+	// The capped sorted set script is the last command in the transaction.
+	evicted, err := redis.Strings(res[len(res)-1], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r.reportEvicted(evicted, groupIdStr)
+	return evicted, nil
 }
 
-func (r *SessionStore) InvalidateSessions(groupId interface{}) error {
+// reportEvicted calls the OnEvict hook, if any, with each evicted session.
+func (r *SessionStore) reportEvicted(evicted []string, groupIdStr string) {
+	if r.onEvict == nil {
+		return
+	}
+
+	for _, evictedIdStr := range evicted {
+		var sessionID id.ID
+		if err := sessionID.UnmarshalText([]byte(evictedIdStr)); err != nil {
+			continue
+		}
+
+		r.onEvict(sessionID, groupIdStr)
+	}
+}
+
+// GroupSessions returns the IDs of the sessions in a group in eviction order,
+// which is oldest first by the time each was last set unless SetSessionScored
+// was used. Sessions that expired since joining the group are listed until
+// they are evicted. Session IDs in the group must be id.IDs.
+func (r *SessionStore) GroupSessions(groupId interface{}) ([]id.ID, error) {
+	defer r.observe("GroupSessions")()
+
 	conn := r.pool.Get()
 	defer conn.Close()
 
 	groupIdStr, err := interfaceToString(groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := redis.Strings(conn.Do("ZRANGE", r.groupKey(groupIdStr), 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	sessionIDs := make([]id.ID, len(members))
+	for i, sessionIdStr := range members {
+		if err := sessionIDs[i].UnmarshalText([]byte(sessionIdStr)); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessionIDs, nil
+}
+
+// GroupSessionCount returns the number of sessions in a group without
+// fetching them. Like GroupSessions, it counts expired sessions until they
+// are evicted.
+func (r *SessionStore) GroupSessionCount(groupId interface{}) (int, error) {
+	defer r.observe("GroupSessionCount")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	groupIdStr, err := interfaceToString(groupId)
+	if err != nil {
+		return 0, err
+	}
+
+	return redis.Int(conn.Do("ZCARD", r.groupKey(groupIdStr)))
+}
+
+// SessionGroup returns the ID of the group a session belongs to, or an empty
+// string if it has none, and NoSessionFoundError if the session does not
+// exist. It reads from the primary even with a replica, since it is used for
+// access checks.
+func (r *SessionStore) SessionGroup(sessionID interface{}) (string, error) {
+	defer r.observe("SessionGroup")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	conn.Send("EXISTS", r.sessionKey(sessionIdStr))
+	conn.Send("GET", r.sessionToGroupKey(sessionIdStr))
+	if err := conn.Flush(); err != nil {
+		return "", err
+	}
+
+	exists, err := redis.Bool(conn.Receive())
+	if err != nil {
+		return "", err
+	}
+
+	gKey, err := redis.String(conn.Receive())
+	if err != nil && err != redis.ErrNil {
+		return "", err
+	}
+
+	if !exists {
+		return "", NoSessionFoundError
+	}
+
+	return strings.TrimPrefix(gKey, r.groupKey("")), nil
+}
+
+// InvalidateSessions deletes every session in a group, and then the group
+// itself. Sessions are deleted in batches so that large groups do not block
+// Redis; a session added to the group while it is being invalidated may
+// survive without its group.
+func (r *SessionStore) InvalidateSessions(groupId interface{}) error {
+	return r.InvalidateSessionsWithContext(context.Background(), groupId)
+}
+
+// InvalidateSessionsWithContext behaves like InvalidateSessions, but gives up
+// once ctx is done. Sessions deleted before then stay deleted.
+func (r *SessionStore) InvalidateSessionsWithContext(ctx context.Context, groupId interface{}) error {
+	defer r.observe("InvalidateSessions")()
+
+	conn, err := getContext(ctx, r.pool)
 	if err != nil {
 		return err
 	}
-	gKey := groupKey(groupIdStr)
+	defer conn.Close()
 
-	if _, err := deleteSortedSetAndKeysScript.Do(conn, gKey, "s", "z"); err != nil {
+	groupIdStr, err := interfaceToString(groupId)
+	if err != nil {
 		return err
 	}
+	gKey := r.groupKey(groupIdStr)
 
-	return nil
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("ZSCAN", gKey, cursor, "COUNT", scanCount))
+		if err != nil {
+			return err
+		}
+
+		cursor, err = redis.Int(values[0], nil)
+		if err != nil {
+			return err
+		}
+
+		memberScores, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return err
+		}
+
+		if err := r.deleteGroupMembers(conn, memberScores); err != nil {
+			return err
+		}
+
+		if cursor == 0 {
+			break
+		}
+	}
+
+	_, err = conn.Do("DEL", gKey)
+	return err
+}
+
+// deleteGroupMembers deletes the keys of the sessions in memberScores, a
+// ZSCAN reply of alternating members and scores. The members are left in the
+// group so as not to disturb the scan.
+func (r *SessionStore) deleteGroupMembers(conn redis.Conn, memberScores []string) error {
+	if len(memberScores) == 0 {
+		return nil
+	}
+
+	keys := []interface{}{}
+	for i := 0; i < len(memberScores); i += 2 {
+		for _, prefix := range r.sessionKeyPrefixes() {
+			keys = append(keys, prefix.(string)+memberScores[i])
+		}
+	}
+
+	_, err := conn.Do("DEL", keys...)
+	return err
 }
 
 func (r *SessionStore) DeleteSession(sessionID interface{}) error {
+	return r.DeleteSessionWithContext(context.Background(), sessionID)
+}
+
+// DeleteSessionWithContext behaves like DeleteSession, but gives up once ctx
+// is done.
+func (r *SessionStore) DeleteSessionWithContext(ctx context.Context, sessionID interface{}) error {
+	defer r.observe("DeleteSession")()
+
+	conn, err := getContext(ctx, r.pool)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return err
+	}
+
+	return r.deleteSession(conn, sessionIdStr)
+}
+
+func (r *SessionStore) deleteSession(conn redis.Conn, sessionIdStr string) error {
+	sKey := r.sessionKey(sessionIdStr)
+	sgKey := r.sessionToGroupKey(sessionIdStr)
+
+	if _, err := deleteSingleSessionScript.Do(conn, sKey, sgKey, r.sessionMetaKey(sessionIdStr), r.sessionNetworkKey(sessionIdStr), sessionIdStr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SessionExtend extends the remaining TTL of a session by the given duration,
+// rather than resetting it to the store's session duration.
+func (r *SessionStore) SessionExtend(sessionID interface{}, by time.Duration) error {
+	defer r.observe("SessionExtend")()
+
 	conn := r.pool.Get()
 	defer conn.Close()
 
-	sessionIdStr, err := interfaceToString(sessionID)
+	sessionIdStr, err := sessionIDToString(sessionID)
 	if err != nil {
 		return err
 	}
-	sKey := sessionKey(sessionIdStr)
-	sgKey := sessionToGroupKey(sessionIdStr)
 
-	if _, err := deleteSingleSessionScript.Do(conn, sKey, sgKey, sessionIdStr); err != nil {
+	ttl, err := redis.Int64(extendSessionScript.Do(conn, r.sessionKey(sessionIdStr), r.sessionToGroupKey(sessionIdStr), r.sessionMetaKey(sessionIdStr), r.sessionNetworkKey(sessionIdStr), int64(by/time.Millisecond)))
+	if err != nil {
 		return err
 	}
 
+	if ttl == -2 {
+		return NoSessionFoundError
+	}
+
 	return nil
 }
 
-func (r *SessionStore) RateLimitCount(client string, bucketRate, bucketCapacity float64) error {
+// Touch resets the TTL of a session to the configured session duration
+// without rewriting it, for sliding expiration. Groups do not expire, so only
+// the session's own keys are touched.
+func (r *SessionStore) Touch(sessionID interface{}) error {
+	defer r.observe("Touch")()
+
 	conn := r.pool.Get()
 	defer conn.Close()
 
-	ok, err := redis.Int(tokenBucketScript.Do(conn, rateLimitKey(client), bucketRate, bucketCapacity, time.Now().UnixNano()))
+	sessionIdStr, err := sessionIDToString(sessionID)
 	if err != nil {
 		return err
 	}
 
-	if ok == 0 {
-		return RateLimitExceededError
+	// With a floor of zero, the script touches any session that exists.
+	touched, err := redis.Int(touchSessionIfAboveScript.Do(conn, r.sessionKey(sessionIdStr), r.sessionToGroupKey(sessionIdStr), r.sessionMetaKey(sessionIdStr), r.sessionNetworkKey(sessionIdStr), 0, (r.sessionDuration+r.gracePeriod)*1000))
+	if err != nil {
+		return err
+	}
+
+	if touched == -1 {
+		return NoSessionFoundError
 	}
 
 	return nil
 }
+
+// TouchSessionIfAbove sets the TTL of a session to newTTL, but only if its
+// remaining TTL is at least floor, so that a session about to expire is not
+// resurrected. It reports whether the TTL was set.
+func (r *SessionStore) TouchSessionIfAbove(sessionID interface{}, floor, newTTL time.Duration) (bool, error) {
+	defer r.observe("TouchSessionIfAbove")()
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	sessionIdStr, err := sessionIDToString(sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	touched, err := redis.Int(touchSessionIfAboveScript.Do(conn, r.sessionKey(sessionIdStr), r.sessionToGroupKey(sessionIdStr), r.sessionMetaKey(sessionIdStr), r.sessionNetworkKey(sessionIdStr), int64(floor/time.Millisecond), int64(newTTL/time.Millisecond)))
+	if err != nil {
+		return false, err
+	}
+
+	if touched == -1 {
+		return false, NoSessionFoundError
+	}
+
+	return touched == 1, nil
+}
+
+// RateLimit counts a request by client against the configured rate limit,
+// returning RateLimitExceededError if it is exceeded. It uses a token bucket
+// with a capacity of RateLimit tokens that refills at RateLimit tokens per
+// RateLimitDuration; each request takes one token.
+func (r *SessionStore) RateLimit(client string) error {
+	if r.rateLimit <= 0 || r.rateLimitDuration <= 0 {
+		return RateLimitNotConfiguredError
+	}
+
+	return r.RateLimitCount(client, float64(r.rateLimit)/float64(r.rateLimitDuration), float64(r.rateLimit))
+}
+
+// RateLimitCount counts a request by client against a token bucket that
+// refills at bucketRate tokens per nanosecond up to bucketCapacity tokens.
+func (r *SessionStore) RateLimitCount(client string, bucketRate, bucketCapacity float64) error {
+	_, _, err := r.RateLimitCountInfo(client, bucketRate, bucketCapacity)
+	return err
+}
+
+// RateLimitCountInfo behaves like RateLimitCount, but also returns the
+// tokens remaining after the request and how long until the next request
+// would be allowed, zero if it would be allowed now, for rate limit response
+// headers such as Retry-After.
+func (r *SessionStore) RateLimitCountInfo(client string, bucketRate, bucketCapacity float64) (remaining float64, retryAfter time.Duration, err error) {
+	defer r.observe("RateLimitCount")()
+
+	if err := r.loadScripts(); err != nil {
+		return 0, 0, err
+	}
+
+	allowed, remaining, retryAfter, err := r.limiter.count(client, bucketRate, bucketCapacity)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !allowed {
+		return remaining, retryAfter, RateLimitExceededError
+	}
+
+	return remaining, retryAfter, nil
+}
+
+// RateLimitWouldAllow reports whether n requests by client would all be
+// allowed by RateLimitCount right now, without counting any of them.
+func (r *SessionStore) RateLimitWouldAllow(client string, bucketRate, bucketCapacity, n float64) (bool, error) {
+	defer r.observe("RateLimitWouldAllow")()
+
+	if err := r.loadScripts(); err != nil {
+		return false, err
+	}
+
+	return r.limiter.wouldAllow(client, bucketRate, bucketCapacity, n)
+}
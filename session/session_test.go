@@ -1,6 +1,10 @@
 package session
 
 import (
+	"context"
+	"crypto/tls"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -138,3 +142,1213 @@ func TestCappedSessions(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCappedSessionsProtected(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		MaxSessions:     2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protectedSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(protectedSessionID, userID, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		sessionID, err := id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sessionStore.SetSessionProtected(sessionID, userID, userID, protectedSessionID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// TODO: get the group key some other way
+	res, err := redis.Strings(conn.Do("ZRANGE", "g"+userID.String(), 0, -1))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(res) != 2 {
+		t.Errorf("Expected 2 sessions in group, got %d: %v", len(res), res)
+	}
+
+	if len(res) > 0 && res[0] != protectedSessionID.String() {
+		t.Errorf("Expected protected session %s to survive eviction, got %v", protectedSessionID, res)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSessionExtend(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, userID, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	ttl, err := redis.Int64(conn.Do("PTTL", "s"+sessionID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SessionExtend(sessionID, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	extendedTTL, err := redis.Int64(conn.Do("PTTL", "s"+sessionID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if extendedTTL <= ttl || extendedTTL > ttl+5000 {
+		t.Errorf("incorrect extended TTL, %d, expected about %d", extendedTTL, ttl+5000)
+	}
+
+	missingSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SessionExtend(missingSessionID, 5*time.Second); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError, got %v", err)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSessionReusedAcrossGroups(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID1, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID2, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, userID1, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, userID2, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// TODO: get the group key some other way
+	res, err := redis.Strings(conn.Do("ZRANGE", "g"+userID1.String(), 0, -1))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(res) != 0 {
+		t.Errorf("Expected 0 sessions in previous group, got %d: %v", len(res), res)
+	}
+
+	res, err = redis.Strings(conn.Do("ZRANGE", "g"+userID2.String(), 0, -1))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(res) != 1 {
+		t.Errorf("Expected 1 session in group, got %d: %v", len(res), res)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	var ops []string
+	slowLog := func(op string, d time.Duration) {
+		ops = append(ops, op)
+	}
+
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		SlowLog:         slowLog,
+		SlowThreshold:   time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, userID, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var session string
+	if err := sessionStore.Session(sessionID, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 2 || ops[0] != "SetSession" || ops[1] != "Session" {
+		t.Errorf("incorrect slow log ops, %v", ops)
+	}
+
+	ops = nil
+	sessionStore, err = NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		SlowLog:         slowLog,
+		SlowThreshold:   time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.DeleteSession(sessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 0 {
+		t.Errorf("unexpected slow log ops, %v", ops)
+	}
+}
+
+func TestSessionGracePeriod(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: 10 * time.Second,
+		GracePeriod:     30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, nil, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var session string
+	stale, err := sessionStore.SessionStale(sessionID, &session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stale {
+		t.Error("fresh session flagged stale")
+	}
+
+	// Simulate the session passing its nominal expiry.
+	if _, err := conn.Do("PEXPIRE", "s"+sessionID.String(), 20000); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err = sessionStore.SessionStale(sessionID, &session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !stale {
+		t.Error("session within grace period not flagged stale")
+	}
+
+	if session != "1" {
+		t.Errorf("incorrect session, %s, expected %s", session, "1")
+	}
+}
+
+func TestCappedSessionsEvicted(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		MaxSessions:     3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionIDs := make([]id.ID, 5)
+	for i := range sessionIDs {
+		sessionIDs[i], err = id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evicted, err := sessionStore.SetSessionEvicted(sessionIDs[i], userID, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i < 3 {
+			if len(evicted) != 0 {
+				t.Errorf("Expected no evicted sessions, got %v", evicted)
+			}
+			continue
+		}
+
+		if len(evicted) != 1 || evicted[0] != sessionIDs[i-3] {
+			t.Errorf("Expected evicted session %s, got %v", sessionIDs[i-3], evicted)
+		}
+	}
+
+	var session string
+	for i, sessionID := range sessionIDs {
+		err := sessionStore.Session(sessionID, &session)
+		if i < 2 && err != NoSessionFoundError {
+			t.Errorf("Expected evicted session %s to be deleted, got %v", sessionID, err)
+		}
+		if i >= 2 && err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestZeroSessionID(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(id.Zero, nil, "1"); err != ZeroSessionIDError {
+		t.Errorf("expected ZeroSessionIDError, got %v", err)
+	}
+
+	var session string
+	if err := sessionStore.Session(id.Zero, &session); err != ZeroSessionIDError {
+		t.Errorf("expected ZeroSessionIDError, got %v", err)
+	}
+}
+
+func TestLazyScriptLoading(t *testing.T) {
+	options := SessionStoreOptions{
+		Addr:            "127.0.0.1:1",
+		SessionDuration: time.Second,
+	}
+
+	sessionStore, err := NewSessionStore(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.RateLimitCount("client", 1, 1); err == nil {
+		t.Error("expected rate limit against unreachable Redis to fail")
+	}
+
+	options.LoadScripts = true
+	if _, err := NewSessionStore(options); err == nil {
+		t.Error("expected eager script loading against unreachable Redis to fail")
+	}
+}
+
+func TestTouchSessionIfAbove(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, nil, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if touched, err := sessionStore.TouchSessionIfAbove(sessionID, 10*time.Second, 2*time.Minute); err != nil {
+		t.Fatal(err)
+	} else if !touched {
+		t.Error("session above the floor not refreshed")
+	}
+
+	ttl, err := redis.Int64(conn.Do("PTTL", "s"+sessionID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl <= int64(time.Minute/time.Millisecond) {
+		t.Errorf("incorrect refreshed TTL, %d", ttl)
+	}
+
+	if _, err := conn.Do("PEXPIRE", "s"+sessionID.String(), 5000); err != nil {
+		t.Fatal(err)
+	}
+
+	if touched, err := sessionStore.TouchSessionIfAbove(sessionID, 10*time.Second, 2*time.Minute); err != nil {
+		t.Fatal(err)
+	} else if touched {
+		t.Error("session below the floor refreshed")
+	}
+
+	ttl, err = redis.Int64(conn.Do("PTTL", "s"+sessionID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl > 5000 {
+		t.Errorf("incorrect TTL, %d", ttl)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, userID, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"s", "z"} {
+		if _, err := conn.Do("PEXPIRE", key+sessionID.String(), 5000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sessionStore.Touch(sessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"s", "z"} {
+		ttl, err := redis.Int64(conn.Do("PTTL", key+sessionID.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ttl <= 55000 || ttl > 60000 {
+			t.Errorf("incorrect TTL for %s key, %d", key, ttl)
+		}
+	}
+
+	var session string
+	if err := sessionStore.Session(sessionID, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	if session != "1" {
+		t.Errorf("incorrect session, %s, expected %s", session, "1")
+	}
+
+	otherSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.Touch(otherSessionID); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError, got %v", err)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetSessionScored(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+		MaxSessions:     2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionIDs := make([]id.ID, 3)
+	for i := range sessionIDs {
+		sessionIDs[i], err = id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The oldest session is trusted, the newer one less so.
+	if err := sessionStore.SetSessionScored(sessionIDs[0], userID, "1", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSessionScored(sessionIDs[1], userID, "1", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSessionScored(sessionIDs[2], userID, "1", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	var session string
+	if err := sessionStore.Session(sessionIDs[1], &session); err != NoSessionFoundError {
+		t.Errorf("expected the low-scored session to be evicted, got %v", err)
+	}
+
+	for _, sessionID := range []id.ID{sessionIDs[0], sessionIDs[2]} {
+		if err := sessionStore.Session(sessionID, &session); err != nil {
+			t.Errorf("expected session %s to survive, got %v", sessionID, err)
+		}
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOnEvict(t *testing.T) {
+	type eviction struct {
+		sessionID id.ID
+		groupId   string
+		deleted   bool
+	}
+
+	var (
+		sessionStore *SessionStore
+		evictions    []eviction
+	)
+
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+		MaxSessions:     2,
+		OnEvict: func(sessionID id.ID, groupId string) {
+			var session string
+			deleted := sessionStore.Session(sessionID, &session) == NoSessionFoundError
+			evictions = append(evictions, eviction{sessionID, groupId, deleted})
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionIDs := make([]id.ID, 3)
+	for i := range sessionIDs {
+		sessionIDs[i], err = id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sessionStore.SetSession(sessionIDs[i], userID, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []eviction{{sessionIDs[0], userID.String(), true}}
+	if len(evictions) != len(expected) || evictions[0] != expected[0] {
+		t.Errorf("incorrect evictions, %v, expected %v", evictions, expected)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGroupSessions(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionIDs := make([]id.ID, 3)
+	for i := range sessionIDs {
+		sessionIDs[i], err = id.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sessionStore.SetSession(sessionIDs[i], userID, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := sessionStore.GroupSessionCount(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != len(sessionIDs) {
+		t.Errorf("incorrect group session count, %d, expected %d", count, len(sessionIDs))
+	}
+
+	groupSessionIDs, err := sessionStore.GroupSessions(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groupSessionIDs) != len(sessionIDs) {
+		t.Fatalf("incorrect group sessions, %v, expected %v", groupSessionIDs, sessionIDs)
+	}
+
+	for i, sessionID := range sessionIDs {
+		if groupSessionIDs[i] != sessionID {
+			t.Errorf("incorrect group session %d, %s, expected %s", i, groupSessionIDs[i], sessionID)
+		}
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Fatal(err)
+	}
+
+	groupSessionIDs, err = sessionStore.GroupSessions(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groupSessionIDs) != 0 {
+		t.Errorf("expected no sessions in invalidated group, got %v", groupSessionIDs)
+	}
+
+	count, err = sessionStore.GroupSessionCount(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("expected no sessions counted in invalidated group, got %d", count)
+	}
+}
+
+func TestSessionGroup(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	groupedSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(groupedSessionID, "tenant", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	ungroupedSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(ungroupedSessionID, nil, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	unknownSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name      string
+		sessionID id.ID
+		group     string
+		err       error
+	}{
+		{"grouped", groupedSessionID, "tenant", nil},
+		{"ungrouped", ungroupedSessionID, "", nil},
+		{"unknown", unknownSessionID, "", NoSessionFoundError},
+	} {
+		group, err := sessionStore.SessionGroup(test.sessionID)
+		if err != test.err {
+			t.Errorf("%s: error %v, expected %v", test.name, err, test.err)
+		}
+
+		if group != test.group {
+			t.Errorf("%s: group %q, expected %q", test.name, group, test.group)
+		}
+	}
+}
+
+func TestSessionReplica(t *testing.T) {
+	replicaAddr := os.Getenv("REDIS_REPLICA_ADDR")
+	if replicaAddr == "" {
+		t.Skip("REDIS_REPLICA_ADDR is not set")
+	}
+
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		ReplicaAddr:     replicaAddr,
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, nil, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Allow the write to replicate.
+	time.Sleep(100 * time.Millisecond)
+
+	var session string
+	if err := sessionStore.Session(sessionID, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	if session != "1" {
+		t.Errorf("incorrect session, %s, expected %s", session, "1")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:              ":6379",
+		SessionDuration:   time.Second,
+		RateLimit:         3,
+		RateLimitDuration: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := sessionStore.RateLimit("client"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Tokens refill continuously, so the bucket may admit one more request
+	// before it runs dry.
+	exceeded := false
+	for i := 0; i < 2 && !exceeded; i++ {
+		switch err := sessionStore.RateLimit("client"); err {
+		case nil:
+		case RateLimitExceededError:
+			exceeded = true
+		default:
+			t.Fatal(err)
+		}
+	}
+
+	if !exceeded {
+		t.Error("expected RateLimitExceededError")
+	}
+
+	if err := sessionStore.RateLimit("other"); err != nil {
+		t.Error(err)
+	}
+
+	sessionStore, err = NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.RateLimit("client"); err != RateLimitNotConfiguredError {
+		t.Errorf("expected RateLimitNotConfiguredError, got %v", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := sessionStore.Ping(ctx); err != nil {
+		t.Error(err)
+	}
+
+	cancel()
+	if err := sessionStore.Ping(ctx); err == nil {
+		t.Error("expected an error for a cancelled context")
+	}
+}
+
+func TestInvalidateLargeGroup(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionIDs, err := id.NewBatch(500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err := sessionStore.SetSession(sessionID, userID, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := redis.Strings(conn.Do("KEYS", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected no keys after invalidation, got %d", len(keys))
+	}
+
+	var session string
+	if err := sessionStore.Session(sessionIDs[0], &session); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError, got %v", err)
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		DB:              1,
+		KeyPrefix:       "app:",
+		SessionDuration: time.Second,
+		MaxSessions:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, userID, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := redis.Strings(conn.Do("KEYS", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) == 0 {
+		t.Error("expected keys in the selected database")
+	}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "app:") {
+			t.Errorf("key %s is not namespaced", key)
+		}
+	}
+
+	otherStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		DB:              1,
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var returnedUserID string
+	if err := otherStore.Session(sessionID, &returnedUserID); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError without the prefix, got %v", err)
+	}
+
+	// Evicting by the group cap must delete the namespaced keys.
+	otherSessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(otherSessionID, userID, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.Session(sessionID, &returnedUserID); err != NoSessionFoundError {
+		t.Errorf("expected NoSessionFoundError for the evicted session, got %v", err)
+	}
+
+	if err := sessionStore.InvalidateSessions(userID); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err = redis.Strings(conn.Do("KEYS", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected no keys after invalidation, got %v", keys)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		TLSConfig:       &tls.Config{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The test server does not speak TLS, so the handshake must fail.
+	if err := sessionStore.Ping(ctx); err == nil {
+		t.Error("expected a TLS handshake error from a plaintext server")
+	}
+}
+
+func TestPoolOptions(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+		MaxActive:       1,
+		Wait:            true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sessionStore.pool.MaxIdle != 3 || sessionStore.pool.IdleTimeout != 5*time.Minute {
+		t.Errorf("incorrect pool defaults, %d idle, %s timeout", sessionStore.pool.MaxIdle, sessionStore.pool.IdleTimeout)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	// The only connection is in use, so Ping must wait until ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := sessionStore.Ping(ctx); err == nil {
+		t.Error("expected Ping to time out waiting for a connection")
+	}
+}
+
+func TestRateLimitWouldAllow(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	rate, capacity := 3/float64(time.Hour), 3.0
+
+	// Each case probes for n requests and then makes them, expecting all of
+	// them to be allowed exactly when the probe said so.
+	for _, test := range []struct {
+		n        float64
+		expected bool
+	}{
+		{4, false},
+		{3, true},
+		{2, false},
+	} {
+		allowed, err := sessionStore.RateLimitWouldAllow("client", rate, capacity, test.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if allowed != test.expected {
+			t.Errorf("RateLimitWouldAllow(%v) = %t, expected %t", test.n, allowed, test.expected)
+		}
+
+		if !allowed {
+			continue
+		}
+
+		for i := 0; i < int(test.n); i++ {
+			if err := sessionStore.RateLimitCount("client", rate, capacity); err != nil {
+				t.Errorf("request %d of %v predicted to be allowed: %v", i+1, test.n, err)
+			}
+		}
+	}
+
+	// The failed probes above must not have consumed anything, and the
+	// prediction for two more requests must hold.
+	allowed := 0
+	for i := 0; i < 2; i++ {
+		if err := sessionStore.RateLimitCount("client", rate, capacity); err == nil {
+			allowed++
+		} else if err != RateLimitExceededError {
+			t.Fatal(err)
+		}
+	}
+
+	if allowed == 2 {
+		t.Error("two requests were allowed after RateLimitWouldAllow predicted otherwise")
+	}
+}
+
+func TestRateLimitCountInfo(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	rate, capacity := 2/float64(time.Hour), 2.0
+
+	remaining, retryAfter, err := sessionStore.RateLimitCountInfo("client", rate, capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if remaining < 1 || remaining > 1.01 || retryAfter != 0 {
+		t.Errorf("incorrect info after first request, %v remaining, retry after %s", remaining, retryAfter)
+	}
+
+	if _, _, err := sessionStore.RateLimitCountInfo("client", rate, capacity); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bucket refills continuously, so allow for one more request.
+	for i := 0; i < 2; i++ {
+		remaining, retryAfter, err = sessionStore.RateLimitCountInfo("client", rate, capacity)
+		if err == RateLimitExceededError {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err != RateLimitExceededError {
+		t.Fatal("rate limit not exceeded")
+	}
+
+	if remaining != 0 {
+		t.Errorf("incorrect remaining tokens, %v", remaining)
+	}
+
+	if retryAfter <= 0 || retryAfter > 30*time.Minute {
+		t.Errorf("incorrect retry after, %s, expected up to 30m", retryAfter)
+	}
+}
@@ -0,0 +1,16 @@
+package session
+
+// Store is the core set of session operations, implemented by SessionStore
+// on Redis and by MemoryStore in process.
+type Store interface {
+	Session(sessionID, session interface{}) error
+	SetSession(sessionID, groupId, session interface{}) error
+	DeleteSession(sessionID interface{}) error
+	InvalidateSessions(groupId interface{}) error
+	RateLimitCount(client string, bucketRate, bucketCapacity float64) error
+}
+
+var (
+	_ Store = (*SessionStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,36 @@
+package session
+
+import (
+	"github.com/O-C-R/auth/id"
+)
+
+// TokenStore adapts a Store to the httpauth.TokenAuthenticator interface, so
+// that session IDs can be used as bearer tokens.
+type TokenStore struct {
+	store      Store
+	newSession func() interface{}
+}
+
+// NewTokenStore returns a TokenStore that decodes sessions from store into
+// the value newSession returns, which must be a pointer.
+func NewTokenStore(store Store, newSession func() interface{}) *TokenStore {
+	return &TokenStore{
+		store:      store,
+		newSession: newSession,
+	}
+}
+
+// AuthenticateToken loads the session with ID token, returning the decoded
+// session as info. A missing or expired session is not authentic.
+func (t *TokenStore) AuthenticateToken(token id.ID) (interface{}, bool, error) {
+	session := t.newSession()
+	if err := t.store.Session(token, session); err != nil {
+		if err == NoSessionFoundError {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	return session, true, nil
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/O-C-R/auth/id"
+)
+
+func TestTokenStore(t *testing.T) {
+	sessionStore, err := NewSessionStore(SessionStoreOptions{
+		Addr:            ":6379",
+		SessionDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := sessionStore.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessionStore.SetSession(sessionID, "group", "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	tokenStore := NewTokenStore(sessionStore, func() interface{} {
+		return new(string)
+	})
+
+	info, authentic, err := tokenStore.AuthenticateToken(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !authentic {
+		t.Error("expected the session ID to be authentic")
+	}
+
+	if user, ok := info.(*string); !ok || *user != "user" {
+		t.Errorf("incorrect info %v", info)
+	}
+
+	unknownID, err := id.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, authentic, err := tokenStore.AuthenticateToken(unknownID); err != nil {
+		t.Fatal(err)
+	} else if authentic {
+		t.Error("expected an unknown session ID not to be authentic")
+	}
+
+	if err := sessionStore.DeleteSession(sessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, authentic, err := tokenStore.AuthenticateToken(sessionID); err != nil {
+		t.Fatal(err)
+	} else if authentic {
+		t.Error("expected a deleted session ID not to be authentic")
+	}
+}